@@ -2,9 +2,12 @@ package golaze
 
 import (
 	"context"
-	"net/http"
+	"errors"
+	"fmt"
 	"os"
 	"os/signal"
+	"sync"
+	"sync/atomic"
 	"syscall"
 	"time"
 
@@ -13,18 +16,32 @@ import (
 )
 
 type AppConfig struct {
-	Name            string
-	Version         string
-	LogLevel        zerolog.Level
-	HealthCheck     *HealthCheck
-	WebApp          *WebApp
-	Worker          *Worker
+	Name        string        `env:"APP_NAME" flag:"name" usage:"application name"`
+	Version     string        `env:"APP_VERSION" flag:"version" usage:"application version"`
+	LogLevel    zerolog.Level `env:"LOG_LEVEL" flag:"log-level" default:"info" usage:"log level"`
+	HealthCheck *HealthCheck
+	WebApp      *WebApp
+	Worker      *Worker
+	// Workers are additional worker pools, each serving its own queue
+	Workers         []*Worker
 	EventBus        *EventBus
-	ShutdownTimeout time.Duration
+	ShutdownTimeout time.Duration `env:"SHUTDOWN_TIMEOUT" flag:"shutdown-timeout" usage:"graceful shutdown timeout"`
+	// GracefulRestart restarts the app on SIGUSR2, handing the listeners to
+	// a new process and exiting once it is ready. Readiness fails from the
+	// start of the handoff.
+	GracefulRestart bool          `env:"GRACEFUL_RESTART" flag:"graceful-restart" usage:"restart gracefully on SIGUSR2"`
+	RestartTimeout  time.Duration `env:"RESTART_TIMEOUT" flag:"restart-timeout" usage:"time for a restarted process to become ready"`
 }
 
 type App struct {
 	*AppConfig
+
+	lock       sync.Mutex
+	components []*registeredComponent
+	router     *workerRouter
+	started    chan struct{}
+	startOnce  sync.Once
+	restarting atomic.Bool
 }
 
 func NewApp(config *AppConfig) *App {
@@ -46,100 +63,245 @@ func NewApp(config *AppConfig) *App {
 		config.ShutdownTimeout = 10 * time.Second
 	}
 
+	if config.RestartTimeout == 0 {
+		config.RestartTimeout = 30 * time.Second
+	}
+
 	config.EventBus = NewEventBus(
-		&EventBusConfig{},
+		&EventBusConfig{
+			Source: config.Name,
+		},
 	)
 
-	return &App{
-		config,
+	app := &App{
+		AppConfig: config,
+		router:    newWorkerRouter(),
+		started:   make(chan struct{}),
 	}
+
+	// The app is ready once all the components started, until it hands over
+	// to a restarted process
+	config.HealthCheck.AddReadinessHook(func() error {
+		select {
+		case <-app.started:
+		default:
+			return errors.New("app is starting")
+		}
+
+		if app.restarting.Load() {
+			return errors.New("app is restarting")
+		}
+		return nil
+	})
+
+	return app
+}
+
+// Started is closed once all the components started, e.g. for tests to read
+// the addresses the servers listen on
+func (app *App) Started() <-chan struct{} {
+	return app.started
 }
 
 func (app *App) AddWorker(worker *Worker) {
 	app.Worker = worker
 }
 
+// AddWorkerPool adds a worker pool serving the worker queue. Each queue is
+// served by a single pool.
+func (app *App) AddWorkerPool(worker *Worker) error {
+	app.lock.Lock()
+	defer app.lock.Unlock()
+
+	if err := checkWorkers(append(app.workers(), worker)); err != nil {
+		return err
+	}
+
+	app.Workers = append(app.Workers, worker)
+
+	return nil
+}
+
+// workers returns Worker followed by the worker pools
+func (app *App) workers() []*Worker {
+	workers := app.Workers
+	if app.Worker != nil {
+		workers = append([]*Worker{app.Worker}, workers...)
+	}
+
+	return workers
+}
+
+// checkWorkers returns an error when several pools serve the same queue
+func checkWorkers(workers []*Worker) error {
+	queues := make(map[string]bool, len(workers))
+	for _, worker := range workers {
+		if queues[worker.Queue] {
+			return fmt.Errorf("queue %s is served by more than one worker", worker.Queue)
+		}
+		queues[worker.Queue] = true
+	}
+
+	return nil
+}
+
+// Run runs the app until SIGINT or SIGTERM
 func (app *App) Run() error {
+	return app.RunContext(context.Background())
+}
+
+// RunContext starts the health check, web app and workers, followed by the
+// registered components, and stops them in reverse order when the context is
+// done, on SIGINT or SIGTERM, or when a component fails. Startup, failure and
+// shutdown errors are returned joined.
+func (app *App) RunContext(ctx context.Context) error {
 	zerolog.SetGlobalLevel(app.LogLevel)
 
-	shutdown := make(chan bool, 1)
+	components, err := app.sortedComponents()
+	if err != nil {
+		return fmt.Errorf("invalid components: %w", err)
+	}
+
 	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGINT, syscall.SIGTERM)
+	defer signal.Stop(signals)
 
-	var healthCheckServer *http.Server
-	var webAppServer *http.Server
-	var workerServer *WorkerServer
+	restarts := make(chan os.Signal, 1)
+	if app.GracefulRestart && len(restartSignals) > 0 {
+		signal.Notify(restarts, restartSignals...)
+		defer signal.Stop(restarts)
+	}
 
-	signal.Notify(signals, syscall.SIGINT, syscall.SIGTERM)
+	// Components are stopped through Stop, not by cancelling their context
+	startCtx := context.WithoutCancel(ctx)
+	started := make([]Component, 0, len(components))
+	failures := make(chan error, len(components))
 
-	go func() {
-		s := <-signals
-		log.Info().Msgf("received signal: %v", s)
-		shutdown <- true
-	}()
+	// watchCtx ends the failure watchers once the app stopped
+	watchCtx, stopWatching := context.WithCancel(startCtx)
+	defer stopWatching()
 
-	healthCheckServer = &http.Server{
-		Addr:           ":" + app.HealthCheck.Port,
-		Handler:        app.HealthCheck.Router,
-		ReadTimeout:    5 * time.Second,
-		WriteTimeout:   5 * time.Second,
-		MaxHeaderBytes: 1 << 20,
-	}
+	var errs []error
 
-	go func() {
-		log.Info().Msgf("starting health check server on port %s", app.HealthCheck.Port)
-		if err := healthCheckServer.ListenAndServe(); err != nil && err != http.ErrServerClosed {
-			log.Fatal().Err(err).Msg("health check server failed")
+	for _, component := range components {
+		if err := component.Start(startCtx); err != nil {
+			errs = append(errs, fmt.Errorf("%s failed to start: %w", component.Name(), err))
+			break
 		}
-	}()
+		started = append(started, component)
 
-	if app.WebApp != nil {
-		webAppServer = &http.Server{
-			Addr:           ":" + app.WebApp.Port,
-			Handler:        app.WebApp.Router,
-			ReadTimeout:    5 * time.Second,
-			WriteTimeout:   5 * time.Second,
-			MaxHeaderBytes: 1 << 20,
+		if c, ok := component.(FailingComponent); ok {
+			go func(failed <-chan error) {
+				select {
+				case err := <-failed:
+					if err != nil {
+						failures <- err
+					}
+				case <-watchCtx.Done():
+				}
+			}(c.Failed())
 		}
+	}
+
+	if len(errs) == 0 {
+		app.startOnce.Do(func() {
+			close(app.started)
+		})
 
-		go func() {
-			log.Info().Msgf("starting web app server on port %s", app.WebApp.Port)
-			if err := webAppServer.ListenAndServe(); err != nil && err != http.ErrServerClosed {
-				log.Fatal().Err(err).Msg("main server failed")
+		// The restart runs in the background so signals and failures are
+		// still handled, cancelling it
+		restartCtx, cancelRestart := context.WithCancel(startCtx)
+		defer cancelRestart()
+		var restarted chan error
+
+	wait:
+		for {
+			select {
+			case s := <-signals:
+				log.Info().Msgf("received signal: %v", s)
+				break wait
+			case <-ctx.Done():
+				log.Info().Msg("context done")
+				break wait
+			case err := <-failures:
+				errs = append(errs, err)
+				break wait
+			case <-restarts:
+				if restarted != nil {
+					log.Info().Msg("received restart signal, already restarting")
+					continue
+				}
+
+				log.Info().Msg("received restart signal, restarting")
+				restarted = make(chan error, 1)
+				go func(restarted chan<- error) {
+					restarted <- app.restart(restartCtx, started)
+				}(restarted)
+			case err := <-restarted:
+				restarted = nil
+				if err != nil {
+					log.Error().Err(err).Msg("restart failed, keeping the current process")
+					continue
+				}
+				break wait
 			}
-		}()
-	}
+		}
 
-	if app.Worker != nil {
-		workerServer = NewWorkerServer()
-		go func() {
-			log.Info().Msg("starting worker server")
-			ctx := context.Background()
-			workerServer.Start(ctx, app.Worker)
-		}()
+		// A restart still running is cancelled, stopping the new process
+		if restarted != nil {
+			cancelRestart()
+			if err := <-restarted; err == nil {
+				log.Info().Msg("restart completed during shutdown")
+			}
+		}
 	}
 
-	<-shutdown
+	log.Info().Msg("shutting down")
 
-	shutdownCtx, cancel := context.WithTimeout(context.Background(), app.ShutdownTimeout)
+	shutdownCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), app.ShutdownTimeout)
 	defer cancel()
 
-	if err := healthCheckServer.Shutdown(shutdownCtx); err != nil {
-		log.Fatal().Err(err).Msg("health check server shutdown failed")
-	}
-
-	if app.WebApp != nil && webAppServer != nil {
-		if err := webAppServer.Shutdown(shutdownCtx); err != nil {
-			log.Fatal().Err(err).Msg("main server shutdown failed")
+	for i := len(started) - 1; i >= 0; i-- {
+		if err := started[i].Stop(shutdownCtx); err != nil {
+			errs = append(errs, fmt.Errorf("%s shutdown failed: %w", started[i].Name(), err))
 		}
 	}
 
-	if app.Worker != nil && workerServer != nil {
-		if err := workerServer.Shutdown(shutdownCtx); err != nil {
-			log.Fatal().Err(err).Msg("worker shutdown failed")
-		}
+	if len(errs) > 0 {
+		log.Error().Err(errors.Join(errs...)).Msg("shutdown complete with errors")
+		return errors.Join(errs...)
 	}
 
 	log.Info().Msg("shutdown complete")
 
 	return nil
 }
+
+// sortedComponents returns the built-in components followed by the registered
+// ones, ordered by their dependencies
+func (app *App) sortedComponents() ([]Component, error) {
+	app.lock.Lock()
+	defer app.lock.Unlock()
+
+	components := []*registeredComponent{
+		{component: app.HealthCheck},
+	}
+
+	if app.WebApp != nil {
+		components = append(components, &registeredComponent{component: app.WebApp})
+	}
+
+	// Worker may be set after pools were added
+	workers := app.workers()
+	if err := checkWorkers(workers); err != nil {
+		return nil, err
+	}
+
+	// Workers route tasks of other queues to each other
+	for _, worker := range workers {
+		worker.router = app.router
+		components = append(components, &registeredComponent{component: worker})
+	}
+
+	return sortComponents(append(components, app.components...))
+}
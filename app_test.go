@@ -0,0 +1,28 @@
+package golaze
+
+import (
+	"context"
+	"errors"
+	"net"
+	"syscall"
+	"testing"
+)
+
+func TestAppAddressInUse(t *testing.T) {
+	busy, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer busy.Close()
+
+	app := NewApp(&AppConfig{
+		Name:        "test",
+		HealthCheck: NewHealthCheck(&HealthCheckConfig{Address: "127.0.0.1:0"}),
+		WebApp:      NewWebApp(&WebAppConfig{Address: busy.Addr().String()}),
+	})
+
+	err = app.RunContext(context.Background())
+	if !errors.Is(err, syscall.EADDRINUSE) {
+		t.Errorf("RunContext error = %v, want %v", err, syscall.EADDRINUSE)
+	}
+}
@@ -0,0 +1,155 @@
+package golaze
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/rs/zerolog/log"
+)
+
+// Component is anything run under the app lifecycle. Start returns once the
+// component is running, leaving long running work in goroutines, and Stop
+// gracefully stops it within the context deadline.
+type Component interface {
+	Name() string
+	Start(ctx context.Context) error
+	Stop(ctx context.Context) error
+}
+
+// FailingComponent is a component that can fail after it started. Failed
+// receives the error that stopped it, making the app shut down.
+type FailingComponent interface {
+	Component
+	Failed() <-chan error
+}
+
+type registeredComponent struct {
+	component    Component
+	dependencies []string
+}
+
+// Register adds a component started by Run after the named dependencies and
+// stopped before them
+func (app *App) Register(component Component, dependencies ...string) {
+	app.lock.Lock()
+	defer app.lock.Unlock()
+
+	app.components = append(app.components, &registeredComponent{
+		component:    component,
+		dependencies: dependencies,
+	})
+}
+
+// sortComponents orders the components so that each one comes after its
+// dependencies, keeping the registration order otherwise
+func sortComponents(components []*registeredComponent) ([]Component, error) {
+	byName := make(map[string]*registeredComponent, len(components))
+	for _, c := range components {
+		name := c.component.Name()
+		if _, ok := byName[name]; ok {
+			return nil, fmt.Errorf("component %s registered twice", name)
+		}
+		byName[name] = c
+	}
+
+	const (
+		visiting = 1
+		visited  = 2
+	)
+
+	state := make(map[string]int, len(components))
+	sorted := make([]Component, 0, len(components))
+
+	var visit func(c *registeredComponent) error
+	visit = func(c *registeredComponent) error {
+		name := c.component.Name()
+		switch state[name] {
+		case visited:
+			return nil
+		case visiting:
+			return fmt.Errorf("component %s has a dependency cycle", name)
+		}
+
+		state[name] = visiting
+		for _, dependency := range c.dependencies {
+			d, ok := byName[dependency]
+			if !ok {
+				return fmt.Errorf("component %s depends on unknown component %s", name, dependency)
+			}
+			if err := visit(d); err != nil {
+				return err
+			}
+		}
+		state[name] = visited
+
+		sorted = append(sorted, c.component)
+		return nil
+	}
+
+	for _, c := range components {
+		if err := visit(c); err != nil {
+			return nil, err
+		}
+	}
+
+	return sorted, nil
+}
+
+// runner adapts a blocking function into a component
+type runner struct {
+	name   string
+	run    func(ctx context.Context) error
+	cancel context.CancelFunc
+	done   chan struct{}
+	failed chan error
+}
+
+// NewRunner returns a component running the blocking function in the
+// background, such as a Pub/Sub consumer or a custom loop. Stop cancels the
+// function context and waits for it to return. An error returned before Stop
+// fails the component.
+func NewRunner(name string, run func(ctx context.Context) error) Component {
+	return &runner{
+		name: name,
+		run:  run,
+	}
+}
+
+func (r *runner) Name() string {
+	return r.name
+}
+
+func (r *runner) Start(ctx context.Context) error {
+	ctx, r.cancel = context.WithCancel(ctx)
+	r.done = make(chan struct{})
+	r.failed = make(chan error, 1)
+
+	go func() {
+		defer close(r.done)
+		if err := r.run(ctx); err != nil && ctx.Err() == nil {
+			log.Error().Err(err).Msgf("%s failed", r.name)
+			r.failed <- fmt.Errorf("%s failed: %w", r.name, err)
+		}
+	}()
+
+	return nil
+}
+
+func (r *runner) Failed() <-chan error {
+	return r.failed
+}
+
+func (r *runner) Stop(ctx context.Context) error {
+	if r.cancel == nil {
+		return nil
+	}
+
+	r.cancel()
+
+	select {
+	case <-r.done:
+		return nil
+	case <-ctx.Done():
+		return fmt.Errorf("%s did not stop: %w", r.name, ctx.Err())
+	}
+}
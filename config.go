@@ -0,0 +1,312 @@
+package golaze
+
+import (
+	"encoding"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/BurntSushi/toml"
+	"gopkg.in/yaml.v3"
+)
+
+// ConfigOptions control where LoadConfig reads values from. Sources are
+// applied in order: `default` tags, files, environment variables and flags.
+//
+// Fields are configured with struct tags:
+//
+//	Port    string        `env:"PORT" flag:"port" default:"8080" usage:"port to listen on"`
+//	Token   string        `env:"TOKEN" required:"true"`
+//	Timeout time.Duration `yaml:"timeout" env:"TIMEOUT" default:"5s"`
+//
+// Nested structs are loaded recursively, pointers to structs are skipped.
+//
+// Defaults are applied to the fields holding their zero value when LoadConfig
+// is called, replacing zero values set beforehand, e.g. false or
+// zerolog.DebugLevel. Set those through a file, env or flag instead.
+type ConfigOptions struct {
+	// Files are decoded as YAML, JSON or TOML by extension. Missing files
+	// are skipped unless RequireFiles is set.
+	Files        []string
+	RequireFiles bool
+	// EnvPrefix is prepended to every env tag
+	EnvPrefix string
+	// FlagSet receives the flags defined by flag tags. Defaults to a new
+	// flag set named after the program.
+	FlagSet *flag.FlagSet
+	// Args are the command-line arguments parsed. Defaults to os.Args[1:].
+	Args []string
+}
+
+// ConfigValidator is implemented by config structs validating themselves
+// once loaded
+type ConfigValidator interface {
+	Validate() error
+}
+
+type configField struct {
+	name  string
+	value reflect.Value
+	field reflect.StructField
+}
+
+// LoadConfig populates the struct pointed to by v. All the errors found,
+// including missing required fields, are returned together.
+func LoadConfig(v interface{}, options *ConfigOptions) error {
+	if options == nil {
+		options = &ConfigOptions{}
+	}
+
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Pointer || rv.Elem().Kind() != reflect.Struct {
+		return fmt.Errorf("config must be a pointer to a struct, got %T", v)
+	}
+
+	fields := configFields(rv.Elem(), "")
+
+	var errs []error
+
+	for _, f := range fields {
+		def, ok := f.field.Tag.Lookup("default")
+		if !ok || !f.value.IsZero() {
+			continue
+		}
+		if err := setConfigValue(f.value, def); err != nil {
+			errs = append(errs, fmt.Errorf("%s: invalid default %q: %v", f.name, def, err))
+		}
+	}
+
+	for _, file := range options.Files {
+		if err := loadConfigFile(v, file, options.RequireFiles); err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	for _, f := range fields {
+		name, ok := f.field.Tag.Lookup("env")
+		if !ok {
+			continue
+		}
+		env, ok := os.LookupEnv(options.EnvPrefix + name)
+		if !ok {
+			continue
+		}
+		if err := setConfigValue(f.value, env); err != nil {
+			errs = append(errs, fmt.Errorf("%s: invalid value %q for %s: %v", f.name, env, options.EnvPrefix+name, err))
+		}
+	}
+
+	if err := parseConfigFlags(fields, options); err != nil {
+		errs = append(errs, err)
+	}
+
+	for _, f := range fields {
+		if f.field.Tag.Get("required") == "true" && f.value.IsZero() {
+			errs = append(errs, fmt.Errorf("%s: required value is missing%s", f.name, configSources(f.field, options)))
+		}
+	}
+
+	if validator, ok := v.(ConfigValidator); ok && len(errs) == 0 {
+		if err := validator.Validate(); err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// configFields returns the settable fields of the struct, recursing into
+// nested structs
+func configFields(v reflect.Value, prefix string) []configField {
+	var fields []configField
+
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		value := v.Field(i)
+		if !field.IsExported() {
+			continue
+		}
+
+		name := prefix + field.Name
+		if field.Type.Kind() == reflect.Struct && !isTextUnmarshaler(value) && field.Type != reflect.TypeOf(time.Time{}) {
+			fields = append(fields, configFields(value, name+".")...)
+			continue
+		}
+
+		fields = append(fields, configField{
+			name:  name,
+			value: value,
+			field: field,
+		})
+	}
+
+	return fields
+}
+
+func loadConfigFile(v interface{}, file string, required bool) error {
+	b, err := os.ReadFile(file)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) && !required {
+			return nil
+		}
+		return fmt.Errorf("failed to read config file: %v", err)
+	}
+
+	switch strings.ToLower(filepath.Ext(file)) {
+	case ".yaml", ".yml":
+		err = yaml.Unmarshal(b, v)
+	case ".json":
+		err = json.Unmarshal(b, v)
+	case ".toml":
+		err = toml.Unmarshal(b, v)
+	default:
+		return fmt.Errorf("unsupported config file format: %s", file)
+	}
+
+	if err != nil {
+		return fmt.Errorf("failed to decode config file %s: %v", file, err)
+	}
+
+	return nil
+}
+
+// configFlag sets the field when the flag is given
+type configFlag struct {
+	value reflect.Value
+}
+
+func (f *configFlag) String() string {
+	if !f.value.IsValid() {
+		return ""
+	}
+	return fmt.Sprint(f.value.Interface())
+}
+
+func (f *configFlag) Set(s string) error {
+	return setConfigValue(f.value, s)
+}
+
+func (f *configFlag) IsBoolFlag() bool {
+	return f.value.IsValid() && f.value.Kind() == reflect.Bool
+}
+
+func parseConfigFlags(fields []configField, options *ConfigOptions) error {
+	flagSet := options.FlagSet
+	if flagSet == nil {
+		flagSet = flag.NewFlagSet(filepath.Base(os.Args[0]), flag.ContinueOnError)
+	}
+
+	defined := false
+	for _, f := range fields {
+		name, ok := f.field.Tag.Lookup("flag")
+		if !ok {
+			continue
+		}
+		// Var panics on flags defined twice, by two fields or by the caller
+		if flagSet.Lookup(name) != nil {
+			return fmt.Errorf("flag -%s is already defined", name)
+		}
+		flagSet.Var(&configFlag{value: f.value}, name, f.field.Tag.Get("usage"))
+		defined = true
+	}
+
+	if !defined {
+		return nil
+	}
+
+	args := options.Args
+	if args == nil {
+		args = os.Args[1:]
+	}
+
+	return flagSet.Parse(args)
+}
+
+func configSources(field reflect.StructField, options *ConfigOptions) string {
+	var sources []string
+	if env, ok := field.Tag.Lookup("env"); ok {
+		sources = append(sources, "env "+options.EnvPrefix+env)
+	}
+	if name, ok := field.Tag.Lookup("flag"); ok {
+		sources = append(sources, "flag -"+name)
+	}
+
+	if len(sources) == 0 {
+		return ""
+	}
+	return " (set " + strings.Join(sources, " or ") + ")"
+}
+
+func isTextUnmarshaler(v reflect.Value) bool {
+	if !v.CanAddr() {
+		return false
+	}
+	_, ok := v.Addr().Interface().(encoding.TextUnmarshaler)
+	return ok
+}
+
+// setConfigValue parses the string into the field
+func setConfigValue(v reflect.Value, s string) error {
+	if isTextUnmarshaler(v) {
+		return v.Addr().Interface().(encoding.TextUnmarshaler).UnmarshalText([]byte(s))
+	}
+
+	if v.Type() == reflect.TypeOf(time.Duration(0)) {
+		d, err := time.ParseDuration(s)
+		if err != nil {
+			return err
+		}
+		v.SetInt(int64(d))
+		return nil
+	}
+
+	switch v.Kind() {
+	case reflect.String:
+		v.SetString(s)
+	case reflect.Bool:
+		b, err := strconv.ParseBool(s)
+		if err != nil {
+			return err
+		}
+		v.SetBool(b)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		i, err := strconv.ParseInt(s, 10, v.Type().Bits())
+		if err != nil {
+			return err
+		}
+		v.SetInt(i)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		u, err := strconv.ParseUint(s, 10, v.Type().Bits())
+		if err != nil {
+			return err
+		}
+		v.SetUint(u)
+	case reflect.Float32, reflect.Float64:
+		f, err := strconv.ParseFloat(s, v.Type().Bits())
+		if err != nil {
+			return err
+		}
+		v.SetFloat(f)
+	case reflect.Slice:
+		parts := strings.Split(s, ",")
+		slice := reflect.MakeSlice(v.Type(), len(parts), len(parts))
+		for i, part := range parts {
+			if err := setConfigValue(slice.Index(i), strings.TrimSpace(part)); err != nil {
+				return err
+			}
+		}
+		v.Set(slice)
+	default:
+		return fmt.Errorf("unsupported type %s", v.Type())
+	}
+
+	return nil
+}
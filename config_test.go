@@ -0,0 +1,141 @@
+package golaze
+
+import (
+	"flag"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+type testConfig struct {
+	Name    string `yaml:"name" env:"NAME" flag:"name" default:"default"`
+	Port    int    `yaml:"port" env:"PORT" flag:"port"`
+	Enabled bool   `yaml:"enabled" env:"ENABLED" flag:"enabled"`
+	Token   string `env:"TOKEN" required:"true"`
+}
+
+func TestLoadConfigPrecedence(t *testing.T) {
+	tests := []struct {
+		name string
+		file string
+		env  string
+		args []string
+		want string
+	}{
+		{
+			name: "default",
+			want: "default",
+		},
+		{
+			name: "file over default",
+			file: "name: file\n",
+			want: "file",
+		},
+		{
+			name: "env over file",
+			file: "name: file\n",
+			env:  "env",
+			want: "env",
+		},
+		{
+			name: "flag over env",
+			file: "name: file\n",
+			env:  "env",
+			args: []string{"-name", "flag"},
+			want: "flag",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			file := filepath.Join(t.TempDir(), "config.yaml")
+			if tt.file != "" {
+				if err := os.WriteFile(file, []byte(tt.file), 0o600); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			t.Setenv("TEST_TOKEN", "token")
+			if tt.env != "" {
+				t.Setenv("TEST_NAME", tt.env)
+			}
+
+			config := &testConfig{}
+			err := LoadConfig(config, &ConfigOptions{
+				Files:     []string{file},
+				EnvPrefix: "TEST_",
+				FlagSet:   flag.NewFlagSet("test", flag.ContinueOnError),
+				Args:      append([]string{}, tt.args...),
+			})
+			if err != nil {
+				t.Fatalf("LoadConfig: %v", err)
+			}
+
+			if config.Name != tt.want {
+				t.Errorf("Name = %q, want %q", config.Name, tt.want)
+			}
+		})
+	}
+}
+
+func TestLoadConfigErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		env     map[string]string
+		flagSet func() *flag.FlagSet
+		// wantErrs are all found in the returned error
+		wantErrs []string
+	}{
+		{
+			name:     "required field missing",
+			wantErrs: []string{"Token: required value is missing (set env TEST_TOKEN)"},
+		},
+		{
+			name: "errors collected together",
+			env:  map[string]string{"TEST_PORT": "port", "TEST_ENABLED": "maybe"},
+			wantErrs: []string{
+				`Port: invalid value "port" for TEST_PORT`,
+				`Enabled: invalid value "maybe" for TEST_ENABLED`,
+				"Token: required value is missing",
+			},
+		},
+		{
+			name: "flag already defined",
+			flagSet: func() *flag.FlagSet {
+				flagSet := flag.NewFlagSet("test", flag.ContinueOnError)
+				flagSet.String("port", "", "")
+				return flagSet
+			},
+			wantErrs: []string{"flag -port is already defined"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for k, v := range tt.env {
+				t.Setenv(k, v)
+			}
+
+			flagSet := flag.NewFlagSet("test", flag.ContinueOnError)
+			if tt.flagSet != nil {
+				flagSet = tt.flagSet()
+			}
+
+			err := LoadConfig(&testConfig{}, &ConfigOptions{
+				EnvPrefix: "TEST_",
+				FlagSet:   flagSet,
+				Args:      []string{},
+			})
+			if err == nil {
+				t.Fatal("LoadConfig succeeded")
+			}
+
+			for _, want := range tt.wantErrs {
+				if !strings.Contains(err.Error(), want) {
+					t.Errorf("error %q does not contain %q", err, want)
+				}
+			}
+		})
+	}
+}
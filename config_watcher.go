@@ -0,0 +1,241 @@
+package golaze
+
+import (
+	"context"
+	"flag"
+	"os"
+	"os/signal"
+	"reflect"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/rs/zerolog"
+	"github.com/rs/zerolog/log"
+)
+
+// ConfigChangedTopic is the event bus topic of configuration changes. The
+// event data is a *ConfigChange of the watched type.
+const ConfigChangedTopic = "config.changed"
+
+// ConfigChange describes a configuration reload
+type ConfigChange[T any] struct {
+	Old *T
+	New *T
+}
+
+type ConfigWatcherConfig struct {
+	// Options are the sources reloaded, files are watched for changes
+	Options *ConfigOptions
+	// EventBus receives a ConfigChange on ConfigChangedTopic after reloads
+	EventBus *EventBus
+	// Interval is how often watched files are checked. Defaults to 5 seconds.
+	Interval time.Duration
+}
+
+// ConfigWatcher reloads a configuration when its files change or on SIGHUP.
+// Invalid configurations are logged and rejected, keeping the current one.
+type ConfigWatcher[T any] struct {
+	*ConfigWatcherConfig
+
+	lock      sync.RWMutex
+	current   *T
+	listeners []func(change *ConfigChange[T])
+	files     map[string]time.Time
+}
+
+// NewConfigWatcher loads the initial configuration
+func NewConfigWatcher[T any](config *ConfigWatcherConfig) (*ConfigWatcher[T], error) {
+	if config.Options == nil {
+		config.Options = &ConfigOptions{}
+	}
+
+	if config.Interval == 0 {
+		config.Interval = 5 * time.Second
+	}
+
+	w := &ConfigWatcher[T]{
+		ConfigWatcherConfig: config,
+	}
+
+	current, err := w.load()
+	if err != nil {
+		return nil, err
+	}
+	w.current = current
+	w.files = w.modTimes()
+	w.apply(nil, current)
+
+	return w, nil
+}
+
+// Current returns the current configuration. It must not be modified.
+func (w *ConfigWatcher[T]) Current() *T {
+	w.lock.RLock()
+	defer w.lock.RUnlock()
+
+	return w.current
+}
+
+// OnChange registers a function called after every configuration change
+func (w *ConfigWatcher[T]) OnChange(f func(change *ConfigChange[T])) {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+
+	w.listeners = append(w.listeners, f)
+}
+
+// Start watches for changes until the context is done
+func (w *ConfigWatcher[T]) Start(ctx context.Context) {
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGHUP)
+	defer signal.Stop(signals)
+
+	ticker := time.NewTicker(w.Interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-signals:
+			log.Info().Msg("received SIGHUP, reloading configuration")
+			w.Reload()
+		case <-ticker.C:
+			files := w.modTimes()
+			if !reflect.DeepEqual(files, w.files) {
+				w.files = files
+				log.Info().Msg("configuration files changed, reloading configuration")
+				w.Reload()
+			}
+		}
+	}
+}
+
+// Reload loads the configuration again, replacing the current one when it is
+// valid and different
+func (w *ConfigWatcher[T]) Reload() error {
+	next, err := w.load()
+	if err != nil {
+		log.Error().Err(err).Msg("configuration rejected, keeping the current one")
+		return err
+	}
+
+	w.lock.Lock()
+	old := w.current
+	if reflect.DeepEqual(old, next) {
+		w.lock.Unlock()
+		return nil
+	}
+	w.current = next
+	listeners := append([]func(change *ConfigChange[T]){}, w.listeners...)
+	w.lock.Unlock()
+
+	w.apply(old, next)
+
+	change := &ConfigChange[T]{
+		Old: old,
+		New: next,
+	}
+
+	for _, listener := range listeners {
+		listener(change)
+	}
+
+	if w.EventBus != nil {
+		w.EventBus.Publish(ConfigChangedTopic, &Event{
+			Data: change,
+		})
+	}
+
+	log.Info().Msg("configuration reloaded")
+
+	return nil
+}
+
+func (w *ConfigWatcher[T]) load() (*T, error) {
+	// Flags can't be defined twice, the config flags are defined again on a
+	// copy of the caller's flag set
+	options := *w.Options
+	options.FlagSet = cloneFlagSet(options.FlagSet)
+
+	next := new(T)
+	if err := LoadConfig(next, &options); err != nil {
+		return nil, err
+	}
+
+	return next, nil
+}
+
+// cloneFlagSet returns a flag set with the flags defined on fs by the caller,
+// leaving out the ones defined by LoadConfig
+func cloneFlagSet(fs *flag.FlagSet) *flag.FlagSet {
+	if fs == nil {
+		return nil
+	}
+
+	clone := flag.NewFlagSet(fs.Name(), fs.ErrorHandling())
+	clone.SetOutput(fs.Output())
+	clone.Usage = fs.Usage
+
+	fs.VisitAll(func(f *flag.Flag) {
+		if _, ok := f.Value.(*configFlag); !ok {
+			clone.Var(f.Value, f.Name, f.Usage)
+		}
+	})
+
+	return clone
+}
+
+// apply updates the global log level when the configuration holds an AppConfig
+func (w *ConfigWatcher[T]) apply(old, next *T) {
+	nextApp := findAppConfig(next)
+	if nextApp == nil {
+		return
+	}
+
+	if oldApp := findAppConfig(old); oldApp == nil || oldApp.LogLevel != nextApp.LogLevel {
+		zerolog.SetGlobalLevel(nextApp.LogLevel)
+		log.Info().Msgf("log level set to %s", nextApp.LogLevel)
+	}
+}
+
+func (w *ConfigWatcher[T]) modTimes() map[string]time.Time {
+	files := make(map[string]time.Time, len(w.Options.Files))
+	for _, file := range w.Options.Files {
+		if info, err := os.Stat(file); err == nil {
+			files[file] = info.ModTime()
+		}
+	}
+
+	return files
+}
+
+// findAppConfig returns v when it is an AppConfig, or its first AppConfig field
+func findAppConfig(v interface{}) *AppConfig {
+	if app, ok := v.(*AppConfig); ok {
+		return app
+	}
+
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Pointer || rv.IsNil() || rv.Elem().Kind() != reflect.Struct {
+		return nil
+	}
+
+	rv = rv.Elem()
+	for i := 0; i < rv.NumField(); i++ {
+		field := rv.Field(i)
+		if !rv.Type().Field(i).IsExported() {
+			continue
+		}
+
+		switch app := field.Interface().(type) {
+		case AppConfig:
+			return field.Addr().Interface().(*AppConfig)
+		case *AppConfig:
+			return app
+		}
+	}
+
+	return nil
+}
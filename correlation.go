@@ -0,0 +1,35 @@
+package golaze
+
+import (
+	"context"
+)
+
+const (
+	CorrelationIDHeader = "X-Correlation-ID"
+	CausationIDHeader   = "X-Causation-ID"
+)
+
+type correlationIDKey struct{}
+type causationIDKey struct{}
+
+// ContextWithCorrelationID returns a copy of ctx carrying the correlation ID
+func ContextWithCorrelationID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, correlationIDKey{}, id)
+}
+
+// CorrelationIDFromContext returns the correlation ID carried by ctx, if any
+func CorrelationIDFromContext(ctx context.Context) string {
+	id, _ := ctx.Value(correlationIDKey{}).(string)
+	return id
+}
+
+// ContextWithCausationID returns a copy of ctx carrying the causation ID
+func ContextWithCausationID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, causationIDKey{}, id)
+}
+
+// CausationIDFromContext returns the causation ID carried by ctx, if any
+func CausationIDFromContext(ctx context.Context) string {
+	id, _ := ctx.Value(causationIDKey{}).(string)
+	return id
+}
@@ -1,10 +1,20 @@
 package golaze
 
 import (
+	"context"
+	"errors"
+	"reflect"
 	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/google/uuid"
 )
 
 type EventBusConfig struct {
+	Source string
+	// Subscribers lists the handlers of each event type. Handlers set before
+	// NewEventBus are subscribed.
 	Subscribers map[string][]EventBusHandler
 	Shutdown    chan bool
 	lock        *sync.RWMutex
@@ -14,12 +24,78 @@ type EventBusHandler interface {
 	Handle(event *Event) error
 }
 
+// EventBusHandlerFunc allows the use of ordinary functions as event handlers.
+type EventBusHandlerFunc func(event *Event) error
+
+// Handle calls f(event).
+func (f EventBusHandlerFunc) Handle(event *Event) error {
+	return f(event)
+}
+
+// Event is the envelope delivered to event bus subscribers. Publish fills in
+// the ID, topic, timestamp and source when they are not set.
 type Event struct {
-	Data interface{}
+	ID            string
+	Topic         string
+	Timestamp     time.Time
+	Source        string
+	Headers       map[string]string
+	CorrelationID string
+	CausationID   string
+	Data          interface{}
+}
+
+// NewEvent creates an event carrying the correlation ID found in the context.
+// The causation ID is set to the ID of the event being handled, if any.
+func NewEvent(ctx context.Context, data interface{}) *Event {
+	event := &Event{
+		ID:      uuid.NewString(),
+		Headers: make(map[string]string),
+		Data:    data,
+	}
+
+	event.CorrelationID = CorrelationIDFromContext(ctx)
+	event.CausationID = CausationIDFromContext(ctx)
+
+	return event
+}
+
+// Context returns a context carrying the event correlation ID and the event ID
+// as causation ID, so anything created from it is linked back to this event.
+func (e *Event) Context(ctx context.Context) context.Context {
+	ctx = ContextWithCorrelationID(ctx, e.CorrelationID)
+	return ContextWithCausationID(ctx, e.ID)
+}
+
+// Subscription is the handle returned when subscribing to the event bus.
+type Subscription struct {
+	EventType string
+	Handler   EventBusHandler
+
+	bus   *EventBus
+	once  bool
+	fired atomic.Bool
+	done  chan struct{}
+	stop  sync.Once
+}
+
+// Unsubscribe removes the subscription from the event bus. It is safe to call
+// more than once.
+func (s *Subscription) Unsubscribe() {
+	s.stop.Do(func() {
+		s.bus.remove(s)
+		close(s.done)
+	})
+}
+
+// Done returns a channel that is closed once the subscription has ended.
+func (s *Subscription) Done() <-chan struct{} {
+	return s.done
 }
 
 type EventBus struct {
 	*EventBusConfig
+	subscriptions map[string][]*Subscription
 }
 
 func NewEventBus(config *EventBusConfig) *EventBus {
@@ -31,43 +107,202 @@ func NewEventBus(config *EventBusConfig) *EventBus {
 		config.lock = &sync.RWMutex{}
 	}
 
-	return &EventBus{
-		config,
+	eb := &EventBus{
+		EventBusConfig: config,
+		subscriptions:  make(map[string][]*Subscription),
+	}
+
+	for eventType, handlers := range config.Subscribers {
+		for _, handler := range handlers {
+			eb.subscriptions[eventType] = append(eb.subscriptions[eventType], eb.newSubscription(eventType, handler, false))
+		}
+	}
+
+	return eb
+}
+
+// Subscribe registers the handler for the given event type
+func (eb *EventBus) Subscribe(eventType string, handler EventBusHandler) *Subscription {
+	return eb.subscribe(eventType, handler, false)
+}
+
+// SubscribeFunc registers a function as handler for the given event type
+func (eb *EventBus) SubscribeFunc(eventType string, handler func(event *Event) error) *Subscription {
+	return eb.subscribe(eventType, EventBusHandlerFunc(handler), false)
+}
+
+// SubscribeOnce registers the handler for the next event of the given type only
+func (eb *EventBus) SubscribeOnce(eventType string, handler EventBusHandler) *Subscription {
+	return eb.subscribe(eventType, handler, true)
+}
+
+// SubscribeContext registers the handler until the context is cancelled
+func (eb *EventBus) SubscribeContext(ctx context.Context, eventType string, handler EventBusHandler) *Subscription {
+	sub := eb.subscribe(eventType, handler, false)
+
+	go func() {
+		select {
+		case <-ctx.Done():
+			sub.Unsubscribe()
+		case <-sub.done:
+		}
+	}()
+
+	return sub
+}
+
+// Unsubscribe removes the first subscription of the handler to the event type.
+// Handlers that can't be compared, such as EventBusHandlerFunc, are never
+// found.
+//
+// Deprecated: use the Unsubscribe method of the Subscription returned by
+// Subscribe.
+func (eb *EventBus) Unsubscribe(eventType string, handler EventBusHandler) {
+	if handler == nil || !reflect.TypeOf(handler).Comparable() {
+		return
+	}
+
+	eb.lock.RLock()
+	var found *Subscription
+	for _, sub := range eb.subscriptions[eventType] {
+		if sub.Handler == handler {
+			found = sub
+			break
+		}
+	}
+	eb.lock.RUnlock()
+
+	if found != nil {
+		found.Unsubscribe()
+	}
+}
+
+func (eb *EventBus) newSubscription(eventType string, handler EventBusHandler, once bool) *Subscription {
+	return &Subscription{
+		EventType: eventType,
+		Handler:   handler,
+		bus:       eb,
+		once:      once,
+		done:      make(chan struct{}),
 	}
 }
 
-func (eb *EventBus) Subscribe(eventType string, handler EventBusHandler) {
+func (eb *EventBus) subscribe(eventType string, handler EventBusHandler, once bool) *Subscription {
+	sub := eb.newSubscription(eventType, handler, once)
+
 	eb.lock.Lock()
 	defer eb.lock.Unlock()
 
+	eb.subscriptions[eventType] = append(eb.subscriptions[eventType], sub)
 	eb.Subscribers[eventType] = append(eb.Subscribers[eventType], handler)
+
+	return sub
 }
 
-func (eb *EventBus) Unsubscribe(eventType string, handler EventBusHandler) {
+func (eb *EventBus) remove(sub *Subscription) {
 	eb.lock.Lock()
 	defer eb.lock.Unlock()
 
-	if _, ok := eb.Subscribers[eventType]; !ok {
+	subscriptions := eb.subscriptions[sub.EventType]
+
+	// Find the subscription and remove it from the list
+	for i, s := range subscriptions {
+		if s == sub {
+			subscriptions = append(subscriptions[:i:i], subscriptions[i+1:]...)
+			break
+		}
+	}
+
+	if len(subscriptions) == 0 {
+		delete(eb.subscriptions, sub.EventType)
+		delete(eb.Subscribers, sub.EventType)
 		return
 	}
 
-	// Find the handler and remove it from the list
-	for i, h := range eb.Subscribers[eventType] {
-		if h == handler {
-			eb.Subscribers[eventType] = append(eb.Subscribers[eventType][:i], eb.Subscribers[eventType][i+1:]...)
-			return
-		}
+	handlers := make([]EventBusHandler, 0, len(subscriptions))
+	for _, s := range subscriptions {
+		handlers = append(handlers, s.Handler)
 	}
 
+	eb.subscriptions[sub.EventType] = subscriptions
+	eb.Subscribers[sub.EventType] = handlers
 }
 
 func (eb *EventBus) Publish(eventType string, event *Event) {
+	eb.prepare(eventType, event)
+
 	eb.lock.RLock()
 	defer eb.lock.RUnlock()
 
-	for _, handler := range eb.Subscribers[eventType] {
-		go func(handler EventBusHandler) {
-			handler.Handle(event)
-		}(handler)
+	for _, sub := range eb.subscriptions[eventType] {
+		if sub.once && !sub.fired.CompareAndSwap(false, true) {
+			continue
+		}
+
+		go func(sub *Subscription) {
+			if sub.once {
+				defer sub.Unsubscribe()
+			}
+			sub.Handler.Handle(event)
+		}(sub)
+	}
+}
+
+// PublishSync delivers the event to all subscribers concurrently and waits for
+// them to finish, returning the joined handler errors.
+func (eb *EventBus) PublishSync(eventType string, event *Event) error {
+	eb.prepare(eventType, event)
+
+	eb.lock.RLock()
+	subscribers := make([]*Subscription, 0, len(eb.subscriptions[eventType]))
+	for _, sub := range eb.subscriptions[eventType] {
+		if sub.once && !sub.fired.CompareAndSwap(false, true) {
+			continue
+		}
+		subscribers = append(subscribers, sub)
+	}
+	eb.lock.RUnlock()
+
+	errs := make(chan error, len(subscribers))
+	for _, sub := range subscribers {
+		go func(sub *Subscription) {
+			if sub.once {
+				defer sub.Unsubscribe()
+			}
+			errs <- sub.Handler.Handle(event)
+		}(sub)
+	}
+
+	var err error
+	for range subscribers {
+		err = errors.Join(err, <-errs)
+	}
+
+	return err
+}
+
+func (eb *EventBus) prepare(eventType string, event *Event) {
+	if event.ID == "" {
+		event.ID = uuid.NewString()
+	}
+
+	if event.Topic == "" {
+		event.Topic = eventType
+	}
+
+	if event.Timestamp.IsZero() {
+		event.Timestamp = time.Now()
+	}
+
+	if event.Source == "" {
+		event.Source = eb.Source
+	}
+
+	if event.Headers == nil {
+		event.Headers = make(map[string]string)
+	}
+
+	if event.CorrelationID == "" {
+		event.CorrelationID = event.ID
 	}
 }
@@ -0,0 +1,42 @@
+package main
+
+import (
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/fandujar/golaze"
+	"github.com/rs/zerolog/log"
+)
+
+type Config struct {
+	App    golaze.AppConfig
+	WebApp golaze.WebAppConfig
+
+	Greeting string        `yaml:"greeting" env:"GREETING" flag:"greeting" default:"Hello, World!" usage:"greeting returned by /"`
+	Delay    time.Duration `yaml:"delay" env:"DELAY" flag:"delay" usage:"delay before answering"`
+	Token    string        `env:"API_TOKEN" required:"true"`
+}
+
+func main() {
+	config := &Config{}
+	err := golaze.LoadConfig(config, &golaze.ConfigOptions{
+		Files: []string{"config.yaml"},
+	})
+	if err != nil {
+		log.Error().Err(err).Msg("invalid configuration")
+		os.Exit(1)
+	}
+
+	app := golaze.NewApp(&config.App)
+	app.WebApp = golaze.NewWebApp(&config.WebApp)
+
+	app.WebApp.Router.Get("/", func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(config.Delay)
+		w.Write([]byte(config.Greeting))
+	})
+
+	if err := app.Run(); err != nil {
+		log.Fatal().Err(err).Msg("app failed")
+	}
+}
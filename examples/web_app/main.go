@@ -5,6 +5,7 @@ import (
 	"time"
 
 	"github.com/fandujar/golaze"
+	"github.com/rs/zerolog/log"
 )
 
 func main() {
@@ -23,6 +24,8 @@ func main() {
 		w.Write([]byte("Hello, World!"))
 	})
 
-	app.Run()
+	if err := app.Run(); err != nil {
+		log.Fatal().Err(err).Msg("app failed")
+	}
 
 }
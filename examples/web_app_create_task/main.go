@@ -6,6 +6,7 @@ import (
 	"time"
 
 	"github.com/fandujar/golaze"
+	"github.com/rs/zerolog/log"
 )
 
 func main() {
@@ -25,6 +26,7 @@ func main() {
 		},
 	)
 
+	app.WebApp.Router.Use(golaze.CorrelationMiddleware)
 	app.WebApp.Router.Get("/", func(w http.ResponseWriter, r *http.Request) {
 		task := golaze.NewTask(
 			&golaze.TaskConfig{
@@ -37,13 +39,13 @@ func main() {
 				},
 			})
 
-		event := &golaze.Event{
-			Data: task,
-		}
+		event := golaze.NewEvent(r.Context(), task)
 
 		app.EventBus.Publish("task", event)
 	})
 
-	app.Run()
+	if err := app.Run(); err != nil {
+		log.Fatal().Err(err).Msg("app failed")
+	}
 
 }
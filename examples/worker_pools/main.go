@@ -0,0 +1,78 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/fandujar/golaze"
+	"github.com/rs/zerolog/log"
+)
+
+func main() {
+	app := golaze.NewApp(
+		&golaze.AppConfig{
+			Name: "Worker Pools",
+		},
+	)
+
+	critical := golaze.NewWorker(
+		&golaze.WorkerConfig{
+			Queue:           "critical",
+			EventBus:        app.EventBus,
+			ConcurrentTasks: 10,
+			MaxRetries:      5,
+			RetryInterval:   time.Second,
+		},
+	)
+
+	bulk := golaze.NewWorker(
+		&golaze.WorkerConfig{
+			Queue:           "bulk",
+			EventBus:        app.EventBus,
+			ConcurrentTasks: 2,
+		},
+	)
+
+	for _, worker := range []*golaze.Worker{critical, bulk} {
+		if err := app.AddWorkerPool(worker); err != nil {
+			log.Fatal().Err(err).Msg("failed to add worker pool")
+		}
+	}
+
+	for i := 0; i < 5; i++ {
+		bulk.Tasks = append(bulk.Tasks, golaze.NewTask(
+			&golaze.TaskConfig{
+				Name:    fmt.Sprintf("bulk task %d", i),
+				Timeout: 10 * time.Second,
+				Exec: func(state *golaze.State, cancel chan bool) error {
+					time.Sleep(3 * time.Second)
+					return nil
+				},
+			},
+		))
+	}
+
+	// Published tasks are run by the worker pool of their queue
+	app.Register(golaze.NewRunner("producer", func(ctx context.Context) error {
+		app.EventBus.Publish("task", &golaze.Event{
+			Data: golaze.NewTask(
+				&golaze.TaskConfig{
+					Name:  "critical task",
+					Queue: "critical",
+					Exec: func(state *golaze.State, cancel chan bool) error {
+						fmt.Println("running critical task")
+						return nil
+					},
+				},
+			),
+		})
+
+		<-ctx.Done()
+		return nil
+	}), "worker-critical", "worker-bulk")
+
+	if err := app.Run(); err != nil {
+		log.Fatal().Err(err).Msg("app failed")
+	}
+}
@@ -5,6 +5,7 @@ import (
 	"time"
 
 	"github.com/fandujar/golaze"
+	"github.com/rs/zerolog/log"
 )
 
 func main() {
@@ -72,5 +73,7 @@ func main() {
 	app.Worker.Tasks = append(app.Worker.Tasks, task2)
 	app.Worker.Tasks = append(app.Worker.Tasks, task3)
 
-	app.Run()
+	if err := app.Run(); err != nil {
+		log.Fatal().Err(err).Msg("app failed")
+	}
 }
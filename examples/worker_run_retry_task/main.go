@@ -39,5 +39,7 @@ func main() {
 		})
 
 	app.Worker.Tasks = append(app.Worker.Tasks, task)
-	app.Run()
+	if err := app.Run(); err != nil {
+		log.Fatal().Err(err).Msg("app failed")
+	}
 }
@@ -0,0 +1,212 @@
+package gcp
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"cloud.google.com/go/pubsub"
+	"github.com/hamba/avro/v2"
+	"github.com/rs/zerolog/log"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+)
+
+// ContentTypeAttribute is the message attribute carrying the codec content type
+const ContentTypeAttribute = "content-type"
+
+// Codec encodes and decodes typed values to and from message payloads
+type Codec interface {
+	ContentType() string
+	// Encoding is the Pub/Sub schema encoding used to validate payloads
+	Encoding() pubsub.SchemaEncoding
+	Encode(v interface{}) ([]byte, error)
+	Decode(data []byte, v interface{}) error
+}
+
+// JSONCodec encodes values as JSON
+type JSONCodec struct{}
+
+func (JSONCodec) ContentType() string {
+	return "application/json"
+}
+
+func (JSONCodec) Encoding() pubsub.SchemaEncoding {
+	return pubsub.EncodingJSON
+}
+
+func (JSONCodec) Encode(v interface{}) ([]byte, error) {
+	return json.Marshal(v)
+}
+
+func (JSONCodec) Decode(data []byte, v interface{}) error {
+	return json.Unmarshal(data, v)
+}
+
+// ProtoCodec encodes protobuf messages, in binary or in JSON when JSON is set
+type ProtoCodec struct {
+	JSON bool
+}
+
+func (c ProtoCodec) ContentType() string {
+	if c.JSON {
+		return "application/protobuf+json"
+	}
+	return "application/protobuf"
+}
+
+func (c ProtoCodec) Encoding() pubsub.SchemaEncoding {
+	if c.JSON {
+		return pubsub.EncodingJSON
+	}
+	return pubsub.EncodingBinary
+}
+
+func (c ProtoCodec) Encode(v interface{}) ([]byte, error) {
+	m, ok := v.(proto.Message)
+	if !ok {
+		return nil, fmt.Errorf("%T is not a proto.Message", v)
+	}
+
+	if c.JSON {
+		return protojson.Marshal(m)
+	}
+	return proto.Marshal(m)
+}
+
+func (c ProtoCodec) Decode(data []byte, v interface{}) error {
+	m, ok := v.(proto.Message)
+	if !ok {
+		return fmt.Errorf("%T is not a proto.Message", v)
+	}
+
+	if c.JSON {
+		return protojson.Unmarshal(data, m)
+	}
+	return proto.Unmarshal(data, m)
+}
+
+// AvroCodec encodes values as binary Avro with the given schema
+type AvroCodec struct {
+	Schema avro.Schema
+}
+
+// NewAvroCodec parses the Avro schema definition and returns a codec for it
+func NewAvroCodec(schema string) (*AvroCodec, error) {
+	s, err := avro.Parse(schema)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse avro schema: %v", err)
+	}
+
+	return &AvroCodec{
+		Schema: s,
+	}, nil
+}
+
+func (c *AvroCodec) ContentType() string {
+	return "application/avro"
+}
+
+func (c *AvroCodec) Encoding() pubsub.SchemaEncoding {
+	return pubsub.EncodingBinary
+}
+
+func (c *AvroCodec) Encode(v interface{}) ([]byte, error) {
+	return avro.Marshal(c.Schema, v)
+}
+
+func (c *AvroCodec) Decode(data []byte, v interface{}) error {
+	return avro.Unmarshal(c.Schema, data, v)
+}
+
+// Encode encodes the value with the client codec into a message carrying the
+// content type attribute, validating it against the configured schema.
+func (client *PubSubClient) Encode(ctx context.Context, v interface{}) (*Message, error) {
+	data, err := client.Codec.Encode(v)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode message: %v", err)
+	}
+
+	if client.schemaClient != nil {
+		_, err := client.schemaClient.ValidateMessageWithID(ctx, data, client.Codec.Encoding(), client.SchemaID)
+		if err != nil {
+			return nil, fmt.Errorf("message does not match schema %s: %v", client.SchemaID, err)
+		}
+	}
+
+	return &Message{
+		Data: data,
+		Attributes: map[string]string{
+			ContentTypeAttribute: client.Codec.ContentType(),
+		},
+	}, nil
+}
+
+// Decode decodes the message payload into v with the client codec
+func (client *PubSubClient) Decode(msg *Message, v interface{}) error {
+	contentType := msg.Attributes[ContentTypeAttribute]
+	if contentType != "" && contentType != client.Codec.ContentType() {
+		return fmt.Errorf("unexpected content type %s, expected %s", contentType, client.Codec.ContentType())
+	}
+
+	if err := client.Codec.Decode(msg.Data, v); err != nil {
+		return fmt.Errorf("failed to decode message %s: %v", msg.ID, err)
+	}
+
+	return nil
+}
+
+// PublishValue encodes the value with the client codec and publishes it
+func (client *PubSubClient) PublishValue(ctx context.Context, v interface{}) (string, error) {
+	msg, err := client.Encode(ctx, v)
+	if err != nil {
+		return "", err
+	}
+
+	return client.Publish(ctx, msg)
+}
+
+// ConsumeValues consumes messages decoded into values of type T. Messages
+// that can't be decoded are passed to the client PoisonHandler, or published
+// to the PoisonTopicID, and acked; without either they are nacked.
+func ConsumeValues[T any](ctx context.Context, client *PubSubClient, handler func(ctx context.Context, msg *Message, v *T) error) error {
+	return client.Consume(ctx, func(ctx context.Context, msg *Message) error {
+		v := new(T)
+		if err := client.Decode(msg, v); err != nil {
+			return client.poison(ctx, msg, err)
+		}
+
+		return handler(ctx, msg, v)
+	})
+}
+
+// poison routes a message that could not be decoded or turned into a task
+func (client *PubSubClient) poison(ctx context.Context, msg *Message, err error) error {
+	log.Error().Err(err).Msgf("poison message %s", msg.ID)
+
+	if client.PoisonHandler != nil {
+		return client.PoisonHandler(ctx, msg, err)
+	}
+
+	if client.poisonTopic == nil {
+		return err
+	}
+
+	attributes := make(map[string]string, len(msg.Attributes)+2)
+	for k, v := range msg.Attributes {
+		attributes[k] = v
+	}
+	attributes["poison-error"] = err.Error()
+	attributes["poison-message-id"] = msg.ID
+
+	result := client.poisonTopic.Publish(ctx, &pubsub.Message{
+		Data:       msg.Data,
+		Attributes: attributes,
+	})
+
+	if _, err := result.Get(ctx); err != nil {
+		return fmt.Errorf("failed to publish poison message %s: %v", msg.ID, err)
+	}
+
+	return nil
+}
@@ -0,0 +1,187 @@
+package gcp
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/fandujar/golaze"
+	"github.com/rs/zerolog/log"
+)
+
+const (
+	// PubSubMessageIDHeader is set on events delivered from Pub/Sub. Events
+	// carrying it are never forwarded back to Pub/Sub.
+	PubSubMessageIDHeader = "Pubsub-Message-Id"
+
+	eventTopicAttribute         = "golaze-topic"
+	eventIDAttribute            = "golaze-event-id"
+	eventCorrelationIDAttribute = "golaze-correlation-id"
+)
+
+// eventEnvelope is the JSON representation of a golaze.Event on the wire.
+type eventEnvelope struct {
+	ID            string            `json:"id"`
+	Topic         string            `json:"topic"`
+	Timestamp     time.Time         `json:"timestamp"`
+	Source        string            `json:"source,omitempty"`
+	Headers       map[string]string `json:"headers,omitempty"`
+	CorrelationID string            `json:"correlation_id,omitempty"`
+	CausationID   string            `json:"causation_id,omitempty"`
+	Data          json.RawMessage   `json:"data,omitempty"`
+}
+
+// EncodeEvent encodes the event envelope as JSON
+func EncodeEvent(event *golaze.Event) ([]byte, error) {
+	data, err := json.Marshal(event.Data)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode event data: %v", err)
+	}
+
+	return json.Marshal(&eventEnvelope{
+		ID:            event.ID,
+		Topic:         event.Topic,
+		Timestamp:     event.Timestamp,
+		Source:        event.Source,
+		Headers:       event.Headers,
+		CorrelationID: event.CorrelationID,
+		CausationID:   event.CausationID,
+		Data:          data,
+	})
+}
+
+// DecodeEvent decodes a JSON event envelope. The event data is left as
+// json.RawMessage for handlers to unmarshal into their own types.
+func DecodeEvent(b []byte) (*golaze.Event, error) {
+	envelope := &eventEnvelope{}
+	if err := json.Unmarshal(b, envelope); err != nil {
+		return nil, fmt.Errorf("failed to decode event: %v", err)
+	}
+
+	if envelope.Headers == nil {
+		envelope.Headers = make(map[string]string)
+	}
+
+	return &golaze.Event{
+		ID:            envelope.ID,
+		Topic:         envelope.Topic,
+		Timestamp:     envelope.Timestamp,
+		Source:        envelope.Source,
+		Headers:       envelope.Headers,
+		CorrelationID: envelope.CorrelationID,
+		CausationID:   envelope.CausationID,
+		Data:          envelope.Data,
+	}, nil
+}
+
+type EventBridgeConfig struct {
+	Client   *PubSubClient
+	EventBus *golaze.EventBus
+	// Topics are the local event bus topics forwarded to the Pub/Sub topic
+	Topics []string
+	// Receive delivers messages from the client subscription to the event
+	// bus. Messages that aren't events are routed like poison messages.
+	Receive bool
+}
+
+// EventBridge forwards local event bus topics to Pub/Sub and delivers Pub/Sub
+// messages back into the event bus.
+type EventBridge struct {
+	*EventBridgeConfig
+
+	lock          sync.Mutex
+	subscriptions []*golaze.Subscription
+}
+
+func NewEventBridge(config *EventBridgeConfig) (*EventBridge, error) {
+	if config.Client == nil {
+		return nil, fmt.Errorf("event bridge requires a pubsub client")
+	}
+
+	if config.EventBus == nil {
+		return nil, fmt.Errorf("event bridge requires an event bus")
+	}
+
+	return &EventBridge{
+		EventBridgeConfig: config,
+	}, nil
+}
+
+// Start subscribes to the local topics and, when Receive is set, blocks
+// delivering Pub/Sub messages to the event bus until the context is done.
+func (b *EventBridge) Start(ctx context.Context) error {
+	b.lock.Lock()
+	for _, t := range b.Topics {
+		b.subscriptions = append(b.subscriptions, b.EventBus.SubscribeContext(ctx, t, golaze.EventBusHandlerFunc(func(event *golaze.Event) error {
+			return b.forward(ctx, event)
+		})))
+	}
+	b.lock.Unlock()
+
+	if !b.Receive {
+		return nil
+	}
+
+	return b.Client.Consume(ctx, func(ctx context.Context, msg *Message) error {
+		return b.deliver(ctx, msg)
+	})
+}
+
+// Stop removes the local subscriptions
+func (b *EventBridge) Stop() {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	for _, sub := range b.subscriptions {
+		sub.Unsubscribe()
+	}
+	b.subscriptions = nil
+}
+
+func (b *EventBridge) forward(ctx context.Context, event *golaze.Event) error {
+	if _, ok := event.Headers[PubSubMessageIDHeader]; ok {
+		return nil
+	}
+
+	data, err := EncodeEvent(event)
+	if err != nil {
+		log.Error().Err(err).Msgf("failed to encode event %s", event.ID)
+		return err
+	}
+
+	_, err = b.Client.Publish(ctx, &Message{
+		Data: data,
+		Attributes: map[string]string{
+			eventTopicAttribute:         event.Topic,
+			eventIDAttribute:            event.ID,
+			eventCorrelationIDAttribute: event.CorrelationID,
+		},
+	})
+	if err != nil {
+		log.Error().Err(err).Msgf("failed to forward event %s", event.ID)
+		return err
+	}
+
+	return nil
+}
+
+func (b *EventBridge) deliver(ctx context.Context, msg *Message) error {
+	event, err := DecodeEvent(msg.Data)
+	if err != nil {
+		return b.Client.poison(ctx, msg, err)
+	}
+
+	if event.Topic == "" {
+		event.Topic = msg.Attributes[eventTopicAttribute]
+	}
+
+	if event.Topic == "" {
+		return b.Client.poison(ctx, msg, fmt.Errorf("message %s has no event topic", msg.ID))
+	}
+
+	event.Headers[PubSubMessageIDHeader] = msg.ID
+
+	return b.EventBus.PublishSync(event.Topic, event)
+}
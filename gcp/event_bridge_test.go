@@ -0,0 +1,228 @@
+package gcp
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/fandujar/golaze"
+)
+
+func TestEventBridgeForward(t *testing.T) {
+	tests := []struct {
+		name      string
+		topic     string
+		headers   map[string]string
+		forwarded bool
+	}{
+		{
+			name:      "bridged topic",
+			topic:     "orders",
+			forwarded: true,
+		},
+		{
+			name:  "other topic",
+			topic: "payments",
+		},
+		{
+			name:    "event from pubsub",
+			topic:   "orders",
+			headers: map[string]string{PubSubMessageIDHeader: "1"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv, options := newTestServer(t, "events")
+			client := newTestClient(t, options, &PubSubClientConfig{TopicID: "events"})
+			bus := golaze.NewEventBus(&golaze.EventBusConfig{})
+
+			bridge, err := NewEventBridge(&EventBridgeConfig{
+				Client:   client,
+				EventBus: bus,
+				Topics:   []string{"orders"},
+			})
+			if err != nil {
+				t.Fatalf("NewEventBridge: %v", err)
+			}
+
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+
+			if err := bridge.Start(ctx); err != nil {
+				t.Fatalf("Start: %v", err)
+			}
+			defer bridge.Stop()
+
+			event := &golaze.Event{
+				ID:            "event-1",
+				Headers:       tt.headers,
+				CorrelationID: "correlation-1",
+				Data:          map[string]string{"order": "42"},
+			}
+			if err := bus.PublishSync(tt.topic, event); err != nil {
+				t.Fatalf("PublishSync: %v", err)
+			}
+
+			messages := srv.Messages()
+			if !tt.forwarded {
+				if len(messages) != 0 {
+					t.Fatalf("got %d messages, want none", len(messages))
+				}
+				return
+			}
+
+			if len(messages) != 1 {
+				t.Fatalf("got %d messages, want 1", len(messages))
+			}
+
+			msg := messages[0]
+			if got := msg.Attributes[eventTopicAttribute]; got != tt.topic {
+				t.Errorf("topic attribute = %q, want %q", got, tt.topic)
+			}
+			if got := msg.Attributes[eventIDAttribute]; got != "event-1" {
+				t.Errorf("event ID attribute = %q, want event-1", got)
+			}
+			if got := msg.Attributes[eventCorrelationIDAttribute]; got != "correlation-1" {
+				t.Errorf("correlation ID attribute = %q, want correlation-1", got)
+			}
+
+			decoded, err := DecodeEvent(msg.Data)
+			if err != nil {
+				t.Fatalf("DecodeEvent: %v", err)
+			}
+			if decoded.ID != "event-1" || decoded.Topic != tt.topic {
+				t.Errorf("decoded event %s on %s, want event-1 on %s", decoded.ID, decoded.Topic, tt.topic)
+			}
+		})
+	}
+}
+
+func TestEventBridgeReceive(t *testing.T) {
+	tests := []struct {
+		name       string
+		data       func(t *testing.T) []byte
+		attributes map[string]string
+		delivered  bool
+		poisoned   bool
+	}{
+		{
+			name: "event envelope",
+			data: func(t *testing.T) []byte {
+				b, err := EncodeEvent(&golaze.Event{ID: "event-1", Topic: "orders", Data: "42"})
+				if err != nil {
+					t.Fatalf("EncodeEvent: %v", err)
+				}
+				return b
+			},
+			delivered: true,
+		},
+		{
+			name: "topic from attribute",
+			data: func(t *testing.T) []byte {
+				b, err := EncodeEvent(&golaze.Event{ID: "event-1", Data: "42"})
+				if err != nil {
+					t.Fatalf("EncodeEvent: %v", err)
+				}
+				return b
+			},
+			attributes: map[string]string{eventTopicAttribute: "orders"},
+			delivered:  true,
+		},
+		{
+			name: "invalid envelope",
+			data: func(t *testing.T) []byte {
+				return []byte("not json")
+			},
+			poisoned: true,
+		},
+		{
+			name: "missing topic",
+			data: func(t *testing.T) []byte {
+				b, err := EncodeEvent(&golaze.Event{ID: "event-1", Data: "42"})
+				if err != nil {
+					t.Fatalf("EncodeEvent: %v", err)
+				}
+				return b
+			},
+			poisoned: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv, options := newTestServer(t, "events")
+			poisoned := make(chan string, 1)
+			client := newTestClient(t, options, &PubSubClientConfig{
+				TopicID:        "events",
+				SubscriptionID: "events-sub",
+				PoisonHandler: func(ctx context.Context, msg *Message, err error) error {
+					poisoned <- msg.ID
+					return nil
+				},
+			})
+			bus := golaze.NewEventBus(&golaze.EventBusConfig{})
+
+			received := make(chan *golaze.Event, 1)
+			bus.SubscribeFunc("orders", func(event *golaze.Event) error {
+				received <- event
+				return nil
+			})
+
+			bridge, err := NewEventBridge(&EventBridgeConfig{
+				Client:   client,
+				EventBus: bus,
+				Receive:  true,
+			})
+			if err != nil {
+				t.Fatalf("NewEventBridge: %v", err)
+			}
+
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+
+			go bridge.Start(ctx)
+
+			// The subscription is created by Start, messages published before
+			// it exists are not delivered
+			waitReceiving(t, client)
+
+			id := srv.Publish("projects/"+testProjectID+"/topics/events", tt.data(t), tt.attributes)
+
+			select {
+			case event := <-received:
+				if !tt.delivered {
+					t.Fatalf("event %s delivered, want none", event.ID)
+				}
+				if got := event.Headers[PubSubMessageIDHeader]; got != id {
+					t.Errorf("message ID header = %q, want %q", got, id)
+				}
+				var data string
+				if err := json.Unmarshal(event.Data.(json.RawMessage), &data); err != nil || data != "42" {
+					t.Errorf("event data = %s, want \"42\"", event.Data)
+				}
+			case poisonID := <-poisoned:
+				if !tt.poisoned || poisonID != id {
+					t.Fatalf("message %s poisoned, want %s delivered", poisonID, id)
+				}
+			case <-time.After(time.Second):
+				t.Fatal("message neither delivered nor poisoned")
+			}
+		})
+	}
+}
+
+// waitReceiving waits until the client receives from its subscription
+func waitReceiving(t *testing.T, client *PubSubClient) {
+	t.Helper()
+
+	for i := 0; i < 100; i++ {
+		if client.receiving.Load() {
+			return
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	t.Fatal("client is not receiving")
+}
@@ -0,0 +1,71 @@
+package gcp
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// SubscriptionHook returns a readiness hook failing when the subscription
+// can't be reached or doesn't exist.
+func (client *PubSubClient) SubscriptionHook() func() error {
+	return func() error {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+
+		exists, err := client.client.Subscription(client.SubscriptionID).Exists(ctx)
+		if err != nil {
+			return fmt.Errorf("failed to check subscription %s: %v", client.SubscriptionID, err)
+		}
+
+		if !exists {
+			return fmt.Errorf("subscription %s does not exist", client.SubscriptionID)
+		}
+
+		return nil
+	}
+}
+
+// ReceiveHook returns a hook failing when messages are not being received,
+// either because no receive loop was started or because it is restarting
+// after a failure.
+func (client *PubSubClient) ReceiveHook() func() error {
+	return func() error {
+		if !client.running.Load() {
+			return fmt.Errorf("receive loop for %s is not running", client.SubscriptionID)
+		}
+
+		if !client.receiving.Load() {
+			return fmt.Errorf("receive loop for %s is restarting", client.SubscriptionID)
+		}
+
+		return nil
+	}
+}
+
+// LastMessageHook returns a hook failing when no message was received within
+// maxAge, counting from the last receive start when none arrived yet.
+func (client *PubSubClient) LastMessageHook(maxAge time.Duration) func() error {
+	return func() error {
+		last := client.LastMessageAge()
+		if last > maxAge {
+			return fmt.Errorf("no message received from %s in %s", client.SubscriptionID, last.Round(time.Second))
+		}
+
+		return nil
+	}
+}
+
+// LastMessageAge returns the time since the last message was received
+func (client *PubSubClient) LastMessageAge() time.Duration {
+	last := client.lastMessage.Load()
+	if last == 0 {
+		last = client.receiveStarted.Load()
+	}
+
+	if last == 0 {
+		return 0
+	}
+
+	return time.Since(time.Unix(0, last))
+}
@@ -3,76 +3,346 @@ package gcp
 import (
 	"context"
 	"fmt"
+	"sync/atomic"
+	"time"
 
 	"cloud.google.com/go/pubsub"
 	"github.com/rs/zerolog/log"
+	"google.golang.org/api/option"
 )
 
 type PubSubClientConfig struct {
 	ProjectID      string
 	TopicID        string
 	SubscriptionID string
+
+	// ClientOptions are passed to the underlying pubsub client, e.g. to point
+	// it at the emulator or at an in-memory pstest server.
+	ClientOptions []option.ClientOption
+
+	// PublishSettings control batching and flow control of published
+	// messages. The pubsub defaults are used when nil.
+	PublishSettings *pubsub.PublishSettings
+	// EnableMessageOrdering must be set to publish messages with ordering keys
+	EnableMessageOrdering bool
+
+	// ReceiveSettings control how messages are pulled from the subscription.
+	// The pubsub defaults are used when nil.
+	ReceiveSettings *pubsub.ReceiveSettings
+	// MaxOutstandingMessages limits the number of unacked messages being
+	// handled at once, overriding ReceiveSettings when set.
+	MaxOutstandingMessages int
+	// NumGoroutines sets the number of goroutines pulling messages,
+	// overriding ReceiveSettings when set.
+	NumGoroutines int
+	// MaxReceiveBackoff caps the delay before receiving is restarted after a
+	// failure. Defaults to one minute.
+	MaxReceiveBackoff time.Duration
+	// OnReceiveError is called by Consume with each receive error before
+	// receiving restarts, e.g. to report it or to cancel the context
+	OnReceiveError func(err error)
+
+	// Subscription describes the subscription created or reconciled before
+	// receiving messages.
+	Subscription SubscriptionSettings
+	// CreateTopic creates the topic and dead-letter topic if they don't exist
+	CreateTopic bool
+
+	// Codec encodes and decodes typed values. Defaults to JSONCodec.
+	Codec Codec
+	// SchemaID validates encoded values against a Pub/Sub schema when set
+	SchemaID string
+	// PoisonHandler is called with messages that can't be decoded, which are
+	// acked when it returns nil
+	PoisonHandler func(ctx context.Context, msg *Message, err error) error
+	// PoisonTopicID receives messages that can't be decoded when no
+	// PoisonHandler is set
+	PoisonTopicID string
 }
 
 type PubSubClient struct {
 	*PubSubClientConfig
-	client *pubsub.Client
+	client       *pubsub.Client
+	schemaClient *pubsub.SchemaClient
+	topic        *pubsub.Topic
+	poisonTopic  *pubsub.Topic
+
+	running        atomic.Bool
+	receiving      atomic.Bool
+	receiveStarted atomic.Int64
+	lastMessage    atomic.Int64
+}
+
+// Message is a Pub/Sub message as seen by golaze publishers and consumers.
+// ID, PublishTime and DeliveryAttempt are only set on received messages.
+type Message struct {
+	ID              string
+	Data            []byte
+	Attributes      map[string]string
+	OrderingKey     string
+	PublishTime     time.Time
+	DeliveryAttempt *int
+}
+
+// PublishResult is the future returned by PublishAsync
+type PublishResult struct {
+	client      *PubSubClient
+	orderingKey string
+	result      *pubsub.PublishResult
+}
+
+// Get blocks until the message is published and returns the server message ID
+func (r *PublishResult) Get(ctx context.Context) (string, error) {
+	id, err := r.result.Get(ctx)
+	if err != nil {
+		// Publishing for an ordering key is paused after a failure, resume it
+		// so the caller can retry.
+		if r.orderingKey != "" {
+			r.client.topic.ResumePublish(r.orderingKey)
+		}
+		return "", fmt.Errorf("failed to publish message: %v", err)
+	}
+
+	return id, nil
+}
+
+// Ready returns a channel that is closed once the result is available
+func (r *PublishResult) Ready() <-chan struct{} {
+	return r.result.Ready()
 }
 
 func NewPubSubClient(config *PubSubClientConfig) (*PubSubClient, error) {
 	ctx := context.Background()
-	client, err := pubsub.NewClient(ctx, config.ProjectID)
+	client, err := pubsub.NewClient(ctx, config.ProjectID, config.ClientOptions...)
 	if err != nil {
 		return nil, fmt.Errorf("pubsub.NewClient: %v", err)
 	}
 
+	var topic *pubsub.Topic
+	if config.TopicID != "" {
+		topic = client.Topic(config.TopicID)
+		if config.PublishSettings != nil {
+			topic.PublishSettings = *config.PublishSettings
+		}
+		topic.EnableMessageOrdering = config.EnableMessageOrdering
+	}
+
+	if config.MaxReceiveBackoff == 0 {
+		config.MaxReceiveBackoff = time.Minute
+	}
+
+	if config.Codec == nil {
+		config.Codec = JSONCodec{}
+	}
+
+	var schemaClient *pubsub.SchemaClient
+	if config.SchemaID != "" {
+		schemaClient, err = pubsub.NewSchemaClient(ctx, config.ProjectID, config.ClientOptions...)
+		if err != nil {
+			return nil, fmt.Errorf("pubsub.NewSchemaClient: %v", err)
+		}
+	}
+
+	var poisonTopic *pubsub.Topic
+	if config.PoisonTopicID != "" {
+		poisonTopic = client.Topic(config.PoisonTopicID)
+	}
+
 	return &PubSubClient{
-		config,
-		client,
+		PubSubClientConfig: config,
+		client:             client,
+		schemaClient:       schemaClient,
+		topic:              topic,
+		poisonTopic:        poisonTopic,
 	}, nil
 }
 
-func (client *PubSubClient) Publish(message string) error {
-	return nil
+// Publish publishes the message and waits for the server message ID
+func (client *PubSubClient) Publish(ctx context.Context, msg *Message) (string, error) {
+	result, err := client.PublishAsync(ctx, msg)
+	if err != nil {
+		return "", err
+	}
+
+	return result.Get(ctx)
 }
 
-func (client *PubSubClient) Subscribe(ctx context.Context) (chan *pubsub.Message, error) {
-	sub := client.client.Subscription(client.SubscriptionID)
+// PublishAsync queues the message for publishing. Messages are sent in
+// batches according to the configured PublishSettings.
+func (client *PubSubClient) PublishAsync(ctx context.Context, msg *Message) (*PublishResult, error) {
+	if client.topic == nil {
+		return nil, fmt.Errorf("pubsub client has no topic configured")
+	}
+
+	if msg.OrderingKey != "" && !client.EnableMessageOrdering {
+		return nil, fmt.Errorf("message ordering is not enabled for topic %s", client.TopicID)
+	}
 
-	// Create the subscription if it doesn't exist.
-	exists, err := sub.Exists(ctx)
-	if err != nil {
-		return nil, err
+	result := client.topic.Publish(ctx, &pubsub.Message{
+		Data:        msg.Data,
+		Attributes:  msg.Attributes,
+		OrderingKey: msg.OrderingKey,
+	})
+
+	return &PublishResult{
+		client:      client,
+		orderingKey: msg.OrderingKey,
+		result:      result,
+	}, nil
+}
+
+// Flush blocks until all pending messages have been sent
+func (client *PubSubClient) Flush() {
+	if client.topic != nil {
+		client.topic.Flush()
 	}
+}
 
-	if !exists {
-		_, err := client.client.CreateSubscription(ctx, client.SubscriptionID, pubsub.SubscriptionConfig{
-			Topic: client.client.Topic(client.TopicID),
-		})
-		if err != nil {
-			return nil, fmt.Errorf("failed to create subscription: %v", err)
-		}
+// Close flushes pending messages, stops the topic and closes the client
+func (client *PubSubClient) Close() error {
+	if client.topic != nil {
+		client.topic.Stop()
+	}
+
+	if client.poisonTopic != nil {
+		client.poisonTopic.Stop()
+	}
+
+	if client.schemaClient != nil {
+		client.schemaClient.Close()
+	}
+
+	return client.client.Close()
+}
+
+// Subscribe forwards received messages on the returned channel, leaving the
+// caller responsible for acking or nacking them. Receive errors are reported on
+// the error channel, dropping them while a previous one is unread, and
+// receiving restarts with backoff. Both channels are closed once the context
+// ends.
+func (client *PubSubClient) Subscribe(ctx context.Context) (<-chan *pubsub.Message, <-chan error, error) {
+	sub, err := client.subscription(ctx)
+	if err != nil {
+		return nil, nil, err
 	}
 
 	// Create a channel to pass messages received from Pub/Sub.
 	msgCh := make(chan *pubsub.Message)
+	errCh := make(chan error, 1)
 
 	// Start a goroutine to receive messages.
 	go func() {
-		err := sub.Receive(ctx, func(ctx context.Context, msg *pubsub.Message) {
-			// Forward message data to channel and then acknowledge the message.
+		defer close(errCh)
+		defer close(msgCh)
+
+		client.receive(ctx, sub, func(ctx context.Context, msg *pubsub.Message) {
 			select {
 			case <-ctx.Done():
-				return
+				msg.Nack()
 			case msgCh <- msg:
-				log.Info().Msgf("received message: %v", string(msg.Data))
+				log.Debug().Msgf("received message: %s", msg.ID)
+			}
+		}, func(err error) {
+			select {
+			case errCh <- err:
+			default:
 			}
 		})
-		if err != nil {
-			// Handling error, for example logging it. Not closing channel here to avoid panic in case of send on closed channel.
-			fmt.Printf("error receiving messages: %v\n", err)
-		}
 	}()
 
-	return msgCh, nil
+	return msgCh, errCh, nil
+}
+
+// Consume calls the handler for every received message, acking it when the
+// handler succeeds and nacking it for redelivery otherwise. It blocks until
+// the context ends, restarting receiving with backoff after failures, and
+// only returns an error when the subscription can't be set up. Receive errors
+// are logged and passed to OnReceiveError.
+func (client *PubSubClient) Consume(ctx context.Context, handler func(ctx context.Context, msg *Message) error) error {
+	sub, err := client.subscription(ctx)
+	if err != nil {
+		return err
+	}
+
+	client.receive(ctx, sub, func(ctx context.Context, m *pubsub.Message) {
+		msg := &Message{
+			ID:              m.ID,
+			Data:            m.Data,
+			Attributes:      m.Attributes,
+			OrderingKey:     m.OrderingKey,
+			PublishTime:     m.PublishTime,
+			DeliveryAttempt: m.DeliveryAttempt,
+		}
+
+		if err := handler(ctx, msg); err != nil {
+			log.Error().Err(err).Msgf("failed to handle message %s", m.ID)
+			m.Nack()
+			return
+		}
+
+		if client.Subscription.EnableExactlyOnceDelivery {
+			if _, err := m.AckWithResult().Get(ctx); err != nil {
+				log.Error().Err(err).Msgf("failed to ack message %s", m.ID)
+			}
+			return
+		}
+
+		m.Ack()
+	}, func(err error) {
+		if client.OnReceiveError != nil {
+			client.OnReceiveError(err)
+		}
+	})
+
+	return nil
+}
+
+// receive runs sub.Receive until the context ends, restarting it with
+// exponential backoff after a failure.
+func (client *PubSubClient) receive(ctx context.Context, sub *pubsub.Subscription, f func(context.Context, *pubsub.Message), onError func(error)) {
+	client.running.Store(true)
+	defer client.running.Store(false)
+
+	backoff := time.Second
+	for {
+		started := time.Now()
+		client.receiveStarted.Store(started.UnixNano())
+		client.receiving.Store(true)
+
+		err := sub.Receive(ctx, func(ctx context.Context, msg *pubsub.Message) {
+			client.lastMessage.Store(time.Now().UnixNano())
+			f(ctx, msg)
+		})
+
+		client.receiving.Store(false)
+		if ctx.Err() != nil {
+			return
+		}
+
+		if err == nil {
+			err = fmt.Errorf("receive stopped unexpectedly")
+		}
+
+		// Start over from the initial backoff if receiving was healthy for a while
+		if time.Since(started) > client.MaxReceiveBackoff {
+			backoff = time.Second
+		}
+
+		log.Error().Err(err).Msgf("error receiving messages from %s, restarting in %s", client.SubscriptionID, backoff)
+		onError(err)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(backoff):
+		}
+
+		backoff = min(backoff*2, client.MaxReceiveBackoff)
+
+		if s, err := client.subscription(ctx); err != nil {
+			log.Error().Err(err).Msgf("failed to get subscription %s", client.SubscriptionID)
+		} else {
+			sub = s
+		}
+	}
 }
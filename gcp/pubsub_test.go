@@ -0,0 +1,189 @@
+package gcp
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"cloud.google.com/go/pubsub"
+	"cloud.google.com/go/pubsub/pstest"
+	"google.golang.org/api/option"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+)
+
+const testProjectID = "test-project"
+
+// newTestServer starts an in-memory Pub/Sub server with the topics created
+func newTestServer(t *testing.T, topics ...string) (*pstest.Server, []option.ClientOption) {
+	t.Helper()
+
+	srv := pstest.NewServer()
+	t.Cleanup(func() { srv.Close() })
+
+	conn, err := grpc.NewClient(srv.Addr, grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		t.Fatalf("failed to dial pstest server: %v", err)
+	}
+	t.Cleanup(func() { conn.Close() })
+
+	options := []option.ClientOption{option.WithGRPCConn(conn)}
+
+	ctx := context.Background()
+	client, err := pubsub.NewClient(ctx, testProjectID, options...)
+	if err != nil {
+		t.Fatalf("failed to create pubsub client: %v", err)
+	}
+
+	for _, topic := range topics {
+		if _, err := client.CreateTopic(ctx, topic); err != nil {
+			t.Fatalf("failed to create topic %s: %v", topic, err)
+		}
+	}
+
+	return srv, options
+}
+
+// newTestClient returns a client of the in-memory server
+func newTestClient(t *testing.T, options []option.ClientOption, config *PubSubClientConfig) *PubSubClient {
+	t.Helper()
+
+	config.ProjectID = testProjectID
+	config.ClientOptions = options
+
+	client, err := NewPubSubClient(config)
+	if err != nil {
+		t.Fatalf("failed to create client: %v", err)
+	}
+	t.Cleanup(func() { client.Close() })
+
+	return client
+}
+
+func TestPublish(t *testing.T) {
+	tests := []struct {
+		name     string
+		config   *PubSubClientConfig
+		msg      *Message
+		wantErr  bool
+		wantSent bool
+	}{
+		{
+			name:     "data",
+			config:   &PubSubClientConfig{TopicID: "events"},
+			msg:      &Message{Data: []byte("hello")},
+			wantSent: true,
+		},
+		{
+			name:     "attributes",
+			config:   &PubSubClientConfig{TopicID: "events"},
+			msg:      &Message{Data: []byte("hello"), Attributes: map[string]string{"kind": "greeting"}},
+			wantSent: true,
+		},
+		{
+			name:     "ordering key",
+			config:   &PubSubClientConfig{TopicID: "events", EnableMessageOrdering: true},
+			msg:      &Message{Data: []byte("hello"), OrderingKey: "customer-1"},
+			wantSent: true,
+		},
+		{
+			name:    "ordering key without ordering",
+			config:  &PubSubClientConfig{TopicID: "events"},
+			msg:     &Message{Data: []byte("hello"), OrderingKey: "customer-1"},
+			wantErr: true,
+		},
+		{
+			name:    "no topic",
+			config:  &PubSubClientConfig{},
+			msg:     &Message{Data: []byte("hello")},
+			wantErr: true,
+		},
+		{
+			name:    "missing topic",
+			config:  &PubSubClientConfig{TopicID: "missing"},
+			msg:     &Message{Data: []byte("hello")},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv, options := newTestServer(t, "events")
+			client := newTestClient(t, options, tt.config)
+
+			id, err := client.Publish(context.Background(), tt.msg)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Publish error = %v, want error %v", err, tt.wantErr)
+			}
+
+			messages := srv.Messages()
+			if !tt.wantSent {
+				if len(messages) != 0 {
+					t.Fatalf("got %d messages, want none", len(messages))
+				}
+				return
+			}
+
+			if len(messages) != 1 {
+				t.Fatalf("got %d messages, want 1", len(messages))
+			}
+
+			msg := messages[0]
+			if msg.ID != id {
+				t.Errorf("message ID = %q, want %q", msg.ID, id)
+			}
+			if string(msg.Data) != string(tt.msg.Data) {
+				t.Errorf("data = %q, want %q", msg.Data, tt.msg.Data)
+			}
+			if msg.OrderingKey != tt.msg.OrderingKey {
+				t.Errorf("ordering key = %q, want %q", msg.OrderingKey, tt.msg.OrderingKey)
+			}
+			for k, v := range tt.msg.Attributes {
+				if msg.Attributes[k] != v {
+					t.Errorf("attribute %s = %q, want %q", k, msg.Attributes[k], v)
+				}
+			}
+		})
+	}
+}
+
+func TestPublishAsync(t *testing.T) {
+	srv, options := newTestServer(t, "events")
+	client := newTestClient(t, options, &PubSubClientConfig{
+		TopicID: "events",
+		PublishSettings: &pubsub.PublishSettings{
+			CountThreshold: 3,
+			DelayThreshold: time.Minute,
+		},
+	})
+
+	ctx := context.Background()
+
+	var results []*PublishResult
+	for i := 0; i < 3; i++ {
+		result, err := client.PublishAsync(ctx, &Message{Data: []byte{byte(i)}})
+		if err != nil {
+			t.Fatalf("PublishAsync: %v", err)
+		}
+		results = append(results, result)
+	}
+
+	ids := make(map[string]bool)
+	for _, result := range results {
+		select {
+		case <-result.Ready():
+		case <-time.After(5 * time.Second):
+			t.Fatal("batch not published")
+		}
+
+		id, err := result.Get(ctx)
+		if err != nil {
+			t.Fatalf("Get: %v", err)
+		}
+		ids[id] = true
+	}
+
+	if len(ids) != 3 || len(srv.Messages()) != 3 {
+		t.Errorf("got %d IDs and %d messages, want 3", len(ids), len(srv.Messages()))
+	}
+}
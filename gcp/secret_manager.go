@@ -0,0 +1,76 @@
+package gcp
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	secretmanager "cloud.google.com/go/secretmanager/apiv1"
+	"cloud.google.com/go/secretmanager/apiv1/secretmanagerpb"
+	"github.com/fandujar/golaze"
+	"github.com/googleapis/gax-go/v2"
+	"google.golang.org/api/option"
+)
+
+// SecretManagerAccessor is the part of the Secret Manager client used by the
+// provider, so tests can substitute a fake
+type SecretManagerAccessor interface {
+	AccessSecretVersion(ctx context.Context, req *secretmanagerpb.AccessSecretVersionRequest, opts ...gax.CallOption) (*secretmanagerpb.AccessSecretVersionResponse, error)
+}
+
+type SecretManagerProviderConfig struct {
+	ProjectID string
+	// Version is the secret version read. Defaults to latest.
+	Version       string
+	ClientOptions []option.ClientOption
+	// Client replaces the Secret Manager client, e.g. with a fake in tests
+	Client SecretManagerAccessor
+}
+
+// SecretManagerProvider reads secrets from GCP Secret Manager. Names are
+// secret IDs in the configured project or full secret version resource names.
+type SecretManagerProvider struct {
+	*SecretManagerProviderConfig
+	closer func() error
+}
+
+func NewSecretManagerProvider(config *SecretManagerProviderConfig) (*SecretManagerProvider, error) {
+	if config.Version == "" {
+		config.Version = "latest"
+	}
+
+	closer := func() error { return nil }
+	if config.Client == nil {
+		client, err := secretmanager.NewClient(context.Background(), config.ClientOptions...)
+		if err != nil {
+			return nil, fmt.Errorf("secretmanager.NewClient: %v", err)
+		}
+		config.Client = client
+		closer = client.Close
+	}
+
+	return &SecretManagerProvider{
+		config,
+		closer,
+	}, nil
+}
+
+func (p *SecretManagerProvider) GetSecret(ctx context.Context, name string) (golaze.Secret, error) {
+	if !strings.HasPrefix(name, "projects/") {
+		name = fmt.Sprintf("projects/%s/secrets/%s/versions/%s", p.ProjectID, name, p.Version)
+	}
+
+	resp, err := p.Client.AccessSecretVersion(ctx, &secretmanagerpb.AccessSecretVersionRequest{
+		Name: name,
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to access secret %s: %v", name, err)
+	}
+
+	return golaze.Secret(resp.GetPayload().GetData()), nil
+}
+
+// Close closes the Secret Manager client created by the provider
+func (p *SecretManagerProvider) Close() error {
+	return p.closer()
+}
@@ -0,0 +1,198 @@
+package gcp
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"time"
+
+	"cloud.google.com/go/pubsub"
+	"github.com/rs/zerolog/log"
+)
+
+// SubscriptionSettings describe the Pub/Sub subscription used by the client.
+// Zero values keep the server defaults, or the current value of an existing
+// subscription.
+type SubscriptionSettings struct {
+	AckDeadline               time.Duration
+	RetentionDuration         time.Duration
+	RetainAckedMessages       bool
+	Filter                    string
+	EnableExactlyOnceDelivery bool
+	RetryPolicy               *pubsub.RetryPolicy
+
+	// DeadLetterTopicID receives messages that could not be delivered after
+	// MaxDeliveryAttempts (5 by default). The Pub/Sub service account needs
+	// publish rights on it and subscribe rights on the subscription.
+	DeadLetterTopicID   string
+	MaxDeliveryAttempts int
+}
+
+// Provision creates the topic, dead-letter topic and subscription if needed
+// and reconciles the subscription with the configured settings.
+func (client *PubSubClient) Provision(ctx context.Context) error {
+	_, err := client.subscription(ctx)
+	return err
+}
+
+// ensureTopic returns the topic, creating it if it doesn't exist and topic
+// creation is enabled.
+func (client *PubSubClient) ensureTopic(ctx context.Context, id string) (*pubsub.Topic, error) {
+	topic := client.client.Topic(id)
+	if !client.CreateTopic {
+		return topic, nil
+	}
+
+	exists, err := topic.Exists(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	if !exists {
+		log.Info().Msgf("creating topic %s", id)
+		topic, err = client.client.CreateTopic(ctx, id)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create topic %s: %v", id, err)
+		}
+	}
+
+	return topic, nil
+}
+
+func (client *PubSubClient) deadLetterPolicy(ctx context.Context) (*pubsub.DeadLetterPolicy, error) {
+	settings := client.Subscription
+	if settings.DeadLetterTopicID == "" {
+		return nil, nil
+	}
+
+	topic, err := client.ensureTopic(ctx, settings.DeadLetterTopicID)
+	if err != nil {
+		return nil, err
+	}
+
+	maxDeliveryAttempts := settings.MaxDeliveryAttempts
+	if maxDeliveryAttempts == 0 {
+		maxDeliveryAttempts = 5
+	}
+
+	return &pubsub.DeadLetterPolicy{
+		DeadLetterTopic:     topic.String(),
+		MaxDeliveryAttempts: maxDeliveryAttempts,
+	}, nil
+}
+
+// subscription returns the configured subscription, creating it if it
+// doesn't exist and reconciling it with the settings otherwise.
+func (client *PubSubClient) subscription(ctx context.Context) (*pubsub.Subscription, error) {
+	topic, err := client.ensureTopic(ctx, client.TopicID)
+	if err != nil {
+		return nil, err
+	}
+
+	deadLetterPolicy, err := client.deadLetterPolicy(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	sub := client.client.Subscription(client.SubscriptionID)
+
+	exists, err := sub.Exists(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	settings := client.Subscription
+	if !exists {
+		sub, err = client.client.CreateSubscription(ctx, client.SubscriptionID, pubsub.SubscriptionConfig{
+			Topic:                     topic,
+			AckDeadline:               settings.AckDeadline,
+			RetentionDuration:         settings.RetentionDuration,
+			RetainAckedMessages:       settings.RetainAckedMessages,
+			Filter:                    settings.Filter,
+			EnableExactlyOnceDelivery: settings.EnableExactlyOnceDelivery,
+			RetryPolicy:               settings.RetryPolicy,
+			DeadLetterPolicy:          deadLetterPolicy,
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to create subscription: %v", err)
+		}
+	} else if err := client.reconcileSubscription(ctx, sub, deadLetterPolicy); err != nil {
+		return nil, err
+	}
+
+	if client.ReceiveSettings != nil {
+		sub.ReceiveSettings = *client.ReceiveSettings
+	}
+
+	if client.MaxOutstandingMessages > 0 {
+		sub.ReceiveSettings.MaxOutstandingMessages = client.MaxOutstandingMessages
+	}
+
+	if client.NumGoroutines > 0 {
+		sub.ReceiveSettings.NumGoroutines = client.NumGoroutines
+	}
+
+	return sub, nil
+}
+
+// reconcileSubscription updates the mutable subscription fields that differ
+// from the settings. The topic and filter can't be changed once created.
+func (client *PubSubClient) reconcileSubscription(ctx context.Context, sub *pubsub.Subscription, deadLetterPolicy *pubsub.DeadLetterPolicy) error {
+	cfg, err := sub.Config(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to get subscription config: %v", err)
+	}
+
+	settings := client.Subscription
+	if client.TopicID != "" && cfg.Topic != nil && cfg.Topic.ID() != client.TopicID {
+		return fmt.Errorf("subscription %s is attached to topic %s, not %s", client.SubscriptionID, cfg.Topic.ID(), client.TopicID)
+	}
+
+	if settings.Filter != "" && cfg.Filter != settings.Filter {
+		return fmt.Errorf("subscription %s filter %q can't be changed to %q", client.SubscriptionID, cfg.Filter, settings.Filter)
+	}
+
+	update := pubsub.SubscriptionConfigToUpdate{}
+	changed := false
+
+	if settings.AckDeadline != 0 && cfg.AckDeadline != settings.AckDeadline {
+		update.AckDeadline = settings.AckDeadline
+		changed = true
+	}
+
+	if settings.RetentionDuration != 0 && cfg.RetentionDuration != settings.RetentionDuration {
+		update.RetentionDuration = settings.RetentionDuration
+		changed = true
+	}
+
+	if settings.RetainAckedMessages && !cfg.RetainAckedMessages {
+		update.RetainAckedMessages = true
+		changed = true
+	}
+
+	if settings.EnableExactlyOnceDelivery && !cfg.EnableExactlyOnceDelivery {
+		update.EnableExactlyOnceDelivery = true
+		changed = true
+	}
+
+	if settings.RetryPolicy != nil && !reflect.DeepEqual(cfg.RetryPolicy, settings.RetryPolicy) {
+		update.RetryPolicy = settings.RetryPolicy
+		changed = true
+	}
+
+	if deadLetterPolicy != nil && !reflect.DeepEqual(cfg.DeadLetterPolicy, deadLetterPolicy) {
+		update.DeadLetterPolicy = deadLetterPolicy
+		changed = true
+	}
+
+	if !changed {
+		return nil
+	}
+
+	log.Info().Msgf("updating subscription %s", client.SubscriptionID)
+	if _, err := sub.Update(ctx, update); err != nil {
+		return fmt.Errorf("failed to update subscription: %v", err)
+	}
+
+	return nil
+}
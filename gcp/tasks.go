@@ -0,0 +1,98 @@
+package gcp
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/fandujar/golaze"
+)
+
+// TaskMessage is the JSON body of a Pub/Sub message triggering a worker task
+type TaskMessage struct {
+	Task    string          `json:"task"`
+	Payload json.RawMessage `json:"payload,omitempty"`
+}
+
+type TaskConsumerConfig struct {
+	Client       *PubSubClient
+	Worker       *golaze.Worker
+	WorkerServer *golaze.WorkerServer
+}
+
+// TaskConsumer runs worker tasks triggered by Pub/Sub messages. Messages are
+// acked when the task succeeds and nacked for redelivery otherwise. Messages
+// that can't be turned into a task are routed as poison messages.
+type TaskConsumer struct {
+	*TaskConsumerConfig
+}
+
+func NewTaskConsumer(config *TaskConsumerConfig) (*TaskConsumer, error) {
+	if config.Client == nil {
+		return nil, fmt.Errorf("task consumer requires a pubsub client")
+	}
+
+	if config.Worker == nil {
+		return nil, fmt.Errorf("task consumer requires a worker")
+	}
+
+	if config.WorkerServer == nil {
+		config.WorkerServer = golaze.NewWorkerServer()
+	}
+
+	return &TaskConsumer{
+		config,
+	}, nil
+}
+
+// Start consumes task messages until the context ends
+func (c *TaskConsumer) Start(ctx context.Context) error {
+	return c.Client.Consume(ctx, c.handle)
+}
+
+func (c *TaskConsumer) handle(ctx context.Context, msg *Message) error {
+	taskMessage := &TaskMessage{}
+	if err := json.Unmarshal(msg.Data, taskMessage); err != nil {
+		return c.Client.poison(ctx, msg, fmt.Errorf("failed to decode task message: %v", err))
+	}
+
+	// Unknown tasks and invalid payloads fail on every redelivery
+	task, err := c.Worker.NewTask(taskMessage.Task, taskMessage.Payload)
+	if err != nil {
+		return c.Client.poison(ctx, msg, err)
+	}
+
+	if task.CorrelationID == "" {
+		task.CorrelationID = msg.Attributes[eventCorrelationIDAttribute]
+	}
+
+	if task.CausationID == "" {
+		task.CausationID = msg.ID
+	}
+
+	return c.WorkerServer.RunTask(ctx, task)
+}
+
+// PublishTask publishes a message triggering the named task with the JSON
+// encoded payload and returns the server message ID
+func (client *PubSubClient) PublishTask(ctx context.Context, name string, payload interface{}) (string, error) {
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return "", fmt.Errorf("failed to encode task payload: %v", err)
+	}
+
+	body, err := json.Marshal(&TaskMessage{
+		Task:    name,
+		Payload: data,
+	})
+	if err != nil {
+		return "", err
+	}
+
+	return client.Publish(ctx, &Message{
+		Data: body,
+		Attributes: map[string]string{
+			eventCorrelationIDAttribute: golaze.CorrelationIDFromContext(ctx),
+		},
+	})
+}
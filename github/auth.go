@@ -0,0 +1,195 @@
+package github
+
+import (
+	"context"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"net/http"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/fandujar/golaze"
+	g "github.com/google/go-github/v63/github"
+)
+
+// TokenProvider returns the token used to authenticate a request
+type TokenProvider func(ctx context.Context) (string, error)
+
+// AppAuth authenticates as a GitHub App installation
+type AppAuth struct {
+	AppID          int64
+	InstallationID int64
+	// PrivateKey is the PEM encoded app private key. PrivateKeyFile is read
+	// when it is empty.
+	PrivateKey     []byte
+	PrivateKeyFile string
+}
+
+// tokenTransport sets the bearer token returned by the provider on every request
+type tokenTransport struct {
+	provider TokenProvider
+	base     http.RoundTripper
+}
+
+func (t *tokenTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	token, err := t.provider(req.Context())
+	if err != nil {
+		return nil, fmt.Errorf("failed to get github token: %v", err)
+	}
+
+	if token == "" {
+		return t.base.RoundTrip(req)
+	}
+
+	// RoundTrippers must not modify the original request
+	req = req.Clone(req.Context())
+	req.Header.Set("Authorization", "Bearer "+token)
+
+	return t.base.RoundTrip(req)
+}
+
+// appTokenSource creates app JWTs and caches installation tokens until
+// shortly before they expire.
+type appTokenSource struct {
+	appID          int64
+	installationID int64
+	key            *rsa.PrivateKey
+	client         *g.Client
+
+	lock      sync.Mutex
+	token     string
+	expiresAt time.Time
+}
+
+func newAppTokenSource(auth *AppAuth, base http.RoundTripper, configure func(*g.Client) (*g.Client, error)) (*appTokenSource, error) {
+	pemKey := auth.PrivateKey
+	if len(pemKey) == 0 {
+		b, err := os.ReadFile(auth.PrivateKeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read github app private key: %v", err)
+		}
+		pemKey = b
+	}
+
+	key, err := parsePrivateKey(pemKey)
+	if err != nil {
+		return nil, err
+	}
+
+	s := &appTokenSource{
+		appID:          auth.AppID,
+		installationID: auth.InstallationID,
+		key:            key,
+	}
+
+	// The app client authenticates with the JWT to request installation tokens
+	client, err := configure(g.NewClient(&http.Client{
+		Transport: &tokenTransport{
+			provider: func(ctx context.Context) (string, error) {
+				return s.JWT()
+			},
+			base: base,
+		},
+	}))
+	if err != nil {
+		return nil, err
+	}
+	s.client = client
+
+	return s, nil
+}
+
+func parsePrivateKey(b []byte) (*rsa.PrivateKey, error) {
+	block, _ := pem.Decode(b)
+	if block == nil {
+		return nil, fmt.Errorf("github app private key is not PEM encoded")
+	}
+
+	if key, err := x509.ParsePKCS1PrivateKey(block.Bytes); err == nil {
+		return key, nil
+	}
+
+	key, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse github app private key: %v", err)
+	}
+
+	rsaKey, ok := key.(*rsa.PrivateKey)
+	if !ok {
+		return nil, fmt.Errorf("github app private key is not an RSA key")
+	}
+
+	return rsaKey, nil
+}
+
+// JWT returns a token authenticating as the app itself, valid for 9 minutes
+func (s *appTokenSource) JWT() (string, error) {
+	now := time.Now()
+
+	header, err := json.Marshal(map[string]string{"alg": "RS256", "typ": "JWT"})
+	if err != nil {
+		return "", err
+	}
+
+	claims, err := json.Marshal(map[string]interface{}{
+		// Backdated to allow for clock drift
+		"iat": now.Add(-time.Minute).Unix(),
+		"exp": now.Add(9 * time.Minute).Unix(),
+		"iss": strconv.FormatInt(s.appID, 10),
+	})
+	if err != nil {
+		return "", err
+	}
+
+	unsigned := base64.RawURLEncoding.EncodeToString(header) + "." + base64.RawURLEncoding.EncodeToString(claims)
+	digest := sha256.Sum256([]byte(unsigned))
+
+	signature, err := rsa.SignPKCS1v15(rand.Reader, s.key, crypto.SHA256, digest[:])
+	if err != nil {
+		return "", fmt.Errorf("failed to sign github app jwt: %v", err)
+	}
+
+	return unsigned + "." + base64.RawURLEncoding.EncodeToString(signature), nil
+}
+
+// Token returns the cached installation token, refreshing it when it expires
+// within a minute
+func (s *appTokenSource) Token(ctx context.Context) (string, error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	if s.token != "" && time.Until(s.expiresAt) > time.Minute {
+		return s.token, nil
+	}
+
+	token, _, err := s.client.Apps.CreateInstallationToken(ctx, s.installationID, nil)
+	if err != nil {
+		return "", fmt.Errorf("failed to create installation token: %v", err)
+	}
+
+	s.token = token.GetToken()
+	s.expiresAt = token.GetExpiresAt().Time
+
+	return s.token, nil
+}
+
+// SecretTokenProvider returns a TokenProvider reading the token from a secret
+func SecretTokenProvider(provider golaze.SecretProvider, name string) TokenProvider {
+	return func(ctx context.Context) (string, error) {
+		secret, err := provider.GetSecret(ctx, name)
+		if err != nil {
+			return "", err
+		}
+
+		return secret.Value(), nil
+	}
+}
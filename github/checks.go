@@ -0,0 +1,34 @@
+package github
+
+import (
+	g "github.com/google/go-github/v63/github"
+)
+
+// CreateStatus sets a commit status on the given ref
+func (gc *GitHubClient) CreateStatus(owner, repo, ref string, status *g.RepoStatus) (*g.RepoStatus, error) {
+	result, _, err := gc.client.Repositories.CreateStatus(gc.Context, owner, repo, ref, status)
+	if err != nil {
+		return nil, err
+	}
+
+	return result, nil
+}
+
+// CreateCheckRun creates a check run. Check runs require GitHub App auth.
+func (gc *GitHubClient) CreateCheckRun(owner, repo string, opts g.CreateCheckRunOptions) (*g.CheckRun, error) {
+	result, _, err := gc.client.Checks.CreateCheckRun(gc.Context, owner, repo, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	return result, nil
+}
+
+func (gc *GitHubClient) UpdateCheckRun(owner, repo string, checkRunID int64, opts g.UpdateCheckRunOptions) (*g.CheckRun, error) {
+	result, _, err := gc.client.Checks.UpdateCheckRun(gc.Context, owner, repo, checkRunID, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	return result, nil
+}
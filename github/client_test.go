@@ -0,0 +1,181 @@
+package github
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	g "github.com/google/go-github/v63/github"
+)
+
+// newTestClient returns a client of the test server handler
+func newTestClient(t *testing.T, handler http.Handler) *GitHubClient {
+	t.Helper()
+
+	srv := httptest.NewServer(handler)
+	t.Cleanup(srv.Close)
+
+	gc, err := NewGitHubClient(&GitHubClientConfig{
+		Token:      "test-token",
+		BaseURL:    srv.URL,
+		MaxRetries: -1,
+	})
+	if err != nil {
+		t.Fatalf("NewGitHubClient: %v", err)
+	}
+
+	return gc
+}
+
+func TestGitHubClient(t *testing.T) {
+	tests := []struct {
+		name     string
+		call     func(gc *GitHubClient) error
+		method   string
+		path     string
+		wantBody map[string]interface{}
+		status   int
+		response string
+		wantErr  bool
+	}{
+		{
+			name: "create issue",
+			call: func(gc *GitHubClient) error {
+				_, err := gc.CreateIssue("owner", "repo", "title", "body")
+				return err
+			},
+			method:   http.MethodPost,
+			path:     "/repos/owner/repo/issues",
+			wantBody: map[string]interface{}{"title": "title", "body": "body"},
+			status:   http.StatusCreated,
+			response: `{"number": 1}`,
+		},
+		{
+			name: "get issue",
+			call: func(gc *GitHubClient) error {
+				_, err := gc.GetIssue("owner", "repo", 1)
+				return err
+			},
+			method:   http.MethodGet,
+			path:     "/repos/owner/repo/issues/1",
+			status:   http.StatusOK,
+			response: `{"number": 1}`,
+		},
+		{
+			name: "close issue",
+			call: func(gc *GitHubClient) error {
+				_, err := gc.CloseIssue("owner", "repo", 1)
+				return err
+			},
+			method:   http.MethodPatch,
+			path:     "/repos/owner/repo/issues/1",
+			wantBody: map[string]interface{}{"state": "closed"},
+			status:   http.StatusOK,
+			response: `{"number": 1, "state": "closed"}`,
+		},
+		{
+			name: "create comment",
+			call: func(gc *GitHubClient) error {
+				_, err := gc.CreateComment("owner", "repo", 1, "comment")
+				return err
+			},
+			method:   http.MethodPost,
+			path:     "/repos/owner/repo/issues/1/comments",
+			wantBody: map[string]interface{}{"body": "comment"},
+			status:   http.StatusCreated,
+			response: `{"id": 1}`,
+		},
+		{
+			name: "remove label",
+			call: func(gc *GitHubClient) error {
+				return gc.RemoveLabel("owner", "repo", 1, "bug")
+			},
+			method: http.MethodDelete,
+			path:   "/repos/owner/repo/issues/1/labels/bug",
+			status: http.StatusOK,
+		},
+		{
+			name: "get pull request",
+			call: func(gc *GitHubClient) error {
+				_, err := gc.GetPullRequest("owner", "repo", 2)
+				return err
+			},
+			method:   http.MethodGet,
+			path:     "/repos/owner/repo/pulls/2",
+			status:   http.StatusOK,
+			response: `{"number": 2}`,
+		},
+		{
+			name: "create status",
+			call: func(gc *GitHubClient) error {
+				_, err := gc.CreateStatus("owner", "repo", "abc", &g.RepoStatus{State: g.String("success")})
+				return err
+			},
+			method:   http.MethodPost,
+			path:     "/repos/owner/repo/statuses/abc",
+			wantBody: map[string]interface{}{"state": "success"},
+			status:   http.StatusCreated,
+			response: `{"state": "success"}`,
+		},
+		{
+			name: "get latest release",
+			call: func(gc *GitHubClient) error {
+				_, err := gc.GetLatestRelease("owner", "repo")
+				return err
+			},
+			method:   http.MethodGet,
+			path:     "/repos/owner/repo/releases/latest",
+			status:   http.StatusOK,
+			response: `{"tag_name": "v1.0.0"}`,
+		},
+		{
+			name: "not found",
+			call: func(gc *GitHubClient) error {
+				_, err := gc.GetIssue("owner", "repo", 3)
+				return err
+			},
+			method:   http.MethodGet,
+			path:     "/repos/owner/repo/issues/3",
+			status:   http.StatusNotFound,
+			response: `{"message": "Not Found"}`,
+			wantErr:  true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			gc := newTestClient(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if r.Method != tt.method || r.URL.Path != tt.path {
+					t.Errorf("request %s %s, want %s %s", r.Method, r.URL.Path, tt.method, tt.path)
+				}
+
+				if got := r.Header.Get("Authorization"); got != "Bearer test-token" {
+					t.Errorf("Authorization = %q, want the token", got)
+				}
+
+				if tt.wantBody != nil {
+					b, _ := io.ReadAll(r.Body)
+					body := make(map[string]interface{})
+					if err := json.Unmarshal(b, &body); err != nil {
+						t.Errorf("invalid request body %s: %v", b, err)
+					}
+					for k, v := range tt.wantBody {
+						if body[k] != v {
+							t.Errorf("body %s = %v, want %v", k, body[k], v)
+						}
+					}
+				}
+
+				w.Header().Set("Content-Type", "application/json")
+				w.WriteHeader(tt.status)
+				io.WriteString(w, tt.response)
+			}))
+
+			if err := tt.call(gc); (err != nil) != tt.wantErr {
+				t.Fatalf("error = %v, want error %v", err, tt.wantErr)
+			}
+		})
+	}
+}
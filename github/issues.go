@@ -0,0 +1,98 @@
+package github
+
+import (
+	g "github.com/google/go-github/v63/github"
+)
+
+func (gc *GitHubClient) CreateIssue(owner, repo, title, body string) (*g.Issue, error) {
+	issue, _, err := gc.client.Issues.Create(gc.Context, owner, repo,
+		&g.IssueRequest{
+			Title: &title,
+			Body:  &body,
+		},
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	return issue, nil
+}
+
+func (gc *GitHubClient) GetIssue(owner, repo string, number int) (*g.Issue, error) {
+	issue, _, err := gc.client.Issues.Get(gc.Context, owner, repo, number)
+	if err != nil {
+		return nil, err
+	}
+
+	return issue, nil
+}
+
+// SearchIssues searches issues and pull requests with the GitHub search syntax
+func (gc *GitHubClient) SearchIssues(query string, opts *g.SearchOptions) (*g.IssuesSearchResult, error) {
+	result, _, err := gc.client.Search.Issues(gc.Context, query, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	return result, nil
+}
+
+func (gc *GitHubClient) UpdateIssue(owner, repo string, number int, request *g.IssueRequest) (*g.Issue, error) {
+	issue, _, err := gc.client.Issues.Edit(gc.Context, owner, repo, number, request)
+	if err != nil {
+		return nil, err
+	}
+
+	return issue, nil
+}
+
+func (gc *GitHubClient) CloseIssue(owner, repo string, number int) (*g.Issue, error) {
+	return gc.UpdateIssue(owner, repo, number, &g.IssueRequest{
+		State: g.String("closed"),
+	})
+}
+
+func (gc *GitHubClient) ReopenIssue(owner, repo string, number int) (*g.Issue, error) {
+	return gc.UpdateIssue(owner, repo, number, &g.IssueRequest{
+		State: g.String("open"),
+	})
+}
+
+// CreateComment comments on an issue or pull request
+func (gc *GitHubClient) CreateComment(owner, repo string, number int, body string) (*g.IssueComment, error) {
+	comment, _, err := gc.client.Issues.CreateComment(gc.Context, owner, repo, number,
+		&g.IssueComment{
+			Body: &body,
+		},
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	return comment, nil
+}
+
+// ListComments returns the first page of comments on an issue or pull request
+func (gc *GitHubClient) ListComments(owner, repo string, number int) ([]*g.IssueComment, error) {
+	comments, _, err := gc.client.Issues.ListComments(gc.Context, owner, repo, number, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	return comments, nil
+}
+
+// AddLabels adds labels to an issue or pull request and returns all its labels
+func (gc *GitHubClient) AddLabels(owner, repo string, number int, labels ...string) ([]*g.Label, error) {
+	result, _, err := gc.client.Issues.AddLabelsToIssue(gc.Context, owner, repo, number, labels)
+	if err != nil {
+		return nil, err
+	}
+
+	return result, nil
+}
+
+func (gc *GitHubClient) RemoveLabel(owner, repo string, number int, label string) error {
+	_, err := gc.client.Issues.RemoveLabelForIssue(gc.Context, owner, repo, number, label)
+	return err
+}
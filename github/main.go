@@ -2,18 +2,57 @@ package github
 
 import (
 	"context"
+	"fmt"
+	"net/http"
+	"net/url"
 	"os"
+	"strings"
+	"time"
 
 	g "github.com/google/go-github/v63/github"
 )
 
 type GitHubClientConfig struct {
-	context context.Context
+	// Context is used for all API calls. Defaults to context.Background().
+	Context context.Context
+
+	// Authentication, in order of precedence. GITHUB_TOKEN is used when
+	// none is set.
+	TokenProvider TokenProvider
+	App           *AppAuth
+	Token         string
+
+	// EnterpriseBaseURL and EnterpriseUploadURL point the client at a GitHub
+	// Enterprise Server. The upload URL defaults to the base URL.
+	EnterpriseBaseURL   string
+	EnterpriseUploadURL string
+
+	// BaseURL overrides the GitHub API URL as is, e.g. to point the client at
+	// an httptest server in tests
+	BaseURL string
+
+	// HTTPClient is used as the base for authenticated requests
+	HTTPClient *http.Client
+
+	// MaxRetries is the number of retries of rate limited requests and of
+	// server errors on idempotent requests. Defaults to 3, use -1 to disable
+	// retries.
+	MaxRetries int
+	// MaxRateLimitWait is the longest the client waits for a rate limit to
+	// reset before returning the error. Defaults to one minute.
+	MaxRateLimitWait time.Duration
+	// DisableCache disables conditional requests with cached ETags
+	DisableCache bool
+
+	// PageSize is the number of items fetched per page by the Each* methods.
+	// Defaults to 100, the GitHub maximum.
+	PageSize int
 }
 
 type GitHubClient struct {
 	*GitHubClientConfig
-	client *g.Client
+	client     *g.Client
+	rateLimits *rateLimitTransport
 }
 
 func GithubAuthToken() string {
@@ -25,32 +64,104 @@ func GithubAuthToken() string {
 }
 
 func NewGitHubClient(config *GitHubClientConfig) (*GitHubClient, error) {
-	if config.context == nil {
-		config.context = context.Background()
+	if config.Context == nil {
+		config.Context = context.Background()
+	}
+
+	if config.HTTPClient == nil {
+		config.HTTPClient = &http.Client{}
+	}
+
+	if config.MaxRetries == 0 {
+		config.MaxRetries = 3
+	}
+
+	if config.MaxRateLimitWait == 0 {
+		config.MaxRateLimitWait = time.Minute
+	}
+
+	if config.PageSize == 0 {
+		config.PageSize = 100
+	}
+
+	transport := config.HTTPClient.Transport
+	if transport == nil {
+		transport = http.DefaultTransport
+	}
+
+	rateLimits := newRateLimitTransport(transport, config)
+	base := http.RoundTripper(rateLimits)
+
+	provider := config.TokenProvider
+	if provider == nil && config.App != nil {
+		source, err := newAppTokenSource(config.App, base, config.configureURLs)
+		if err != nil {
+			return nil, err
+		}
+		provider = source.Token
+	}
+
+	if provider == nil {
+		token := config.Token
+		if token == "" {
+			token = GithubAuthToken()
+		}
+		provider = func(ctx context.Context) (string, error) {
+			return token, nil
+		}
+	}
+
+	httpClient := *config.HTTPClient
+	httpClient.Transport = &tokenTransport{
+		provider: provider,
+		base:     base,
+	}
+
+	client, err := config.configureURLs(g.NewClient(&httpClient))
+	if err != nil {
+		return nil, err
 	}
 
-	client := g.NewClient(nil).WithAuthToken(GithubAuthToken())
 	return &GitHubClient{
-		config,
-		client,
+		GitHubClientConfig: config,
+		client:             client,
+		rateLimits:         rateLimits,
 	}, nil
 }
 
-func (gc *GitHubClient) CreateIssue(owner, repo, title, body string) error {
-	_, _, err := gc.client.Repositories.Get(gc.context, owner, repo)
-	if err != nil {
-		return err
+// configureURLs applies the enterprise or base URL overrides to the client
+func (config *GitHubClientConfig) configureURLs(client *g.Client) (*g.Client, error) {
+	if config.EnterpriseBaseURL != "" {
+		uploadURL := config.EnterpriseUploadURL
+		if uploadURL == "" {
+			uploadURL = config.EnterpriseBaseURL
+		}
+
+		c, err := client.WithEnterpriseURLs(config.EnterpriseBaseURL, uploadURL)
+		if err != nil {
+			return nil, fmt.Errorf("invalid enterprise url: %v", err)
+		}
+		client = c
 	}
 
-	_, _, err = gc.client.Issues.Create(gc.context, owner, repo,
-		&g.IssueRequest{
-			Title: &title,
-			Body:  &body,
-		},
-	)
-	if err != nil {
-		return err
+	if config.BaseURL != "" {
+		baseURL, err := url.Parse(config.BaseURL)
+		if err != nil {
+			return nil, fmt.Errorf("invalid base url: %v", err)
+		}
+
+		if !strings.HasSuffix(baseURL.Path, "/") {
+			baseURL.Path += "/"
+		}
+
+		client.BaseURL = baseURL
+		client.UploadURL = baseURL
 	}
 
-	return nil
+	return client, nil
+}
+
+// Client returns the underlying go-github client for calls not wrapped here
+func (gc *GitHubClient) Client() *g.Client {
+	return gc.client
 }
@@ -0,0 +1,109 @@
+package github
+
+import (
+	"errors"
+
+	g "github.com/google/go-github/v63/github"
+)
+
+// ErrStopIteration can be returned by an iteration callback to stop fetching
+// further pages without reporting an error
+var ErrStopIteration = errors.New("stop iteration")
+
+// paginate fetches pages lazily, calling f for every item until the last page,
+// an error, or the client context being cancelled
+func paginate[T any](gc *GitHubClient, opts *g.ListOptions, list func() ([]T, *g.Response, error), f func(T) error) error {
+	if opts.PerPage == 0 {
+		opts.PerPage = gc.PageSize
+	}
+
+	for {
+		if err := gc.Context.Err(); err != nil {
+			return err
+		}
+
+		items, resp, err := list()
+		if err != nil {
+			return err
+		}
+
+		for _, item := range items {
+			if err := f(item); err != nil {
+				if errors.Is(err, ErrStopIteration) {
+					return nil
+				}
+				return err
+			}
+		}
+
+		if resp.NextPage == 0 {
+			return nil
+		}
+		opts.Page = resp.NextPage
+	}
+}
+
+// copyOptions returns a copy of the options, or new ones when nil, so pages
+// are set without changing the caller's options
+func copyOptions[T any](opts *T) *T {
+	c := new(T)
+	if opts != nil {
+		*c = *opts
+	}
+
+	return c
+}
+
+// EachIssue calls f for every issue of the repository matching the options
+func (gc *GitHubClient) EachIssue(owner, repo string, opts *g.IssueListByRepoOptions, f func(*g.Issue) error) error {
+	opts = copyOptions(opts)
+
+	return paginate(gc, &opts.ListOptions, func() ([]*g.Issue, *g.Response, error) {
+		return gc.client.Issues.ListByRepo(gc.Context, owner, repo, opts)
+	}, f)
+}
+
+// EachPullRequest calls f for every pull request of the repository matching the options
+func (gc *GitHubClient) EachPullRequest(owner, repo string, opts *g.PullRequestListOptions, f func(*g.PullRequest) error) error {
+	opts = copyOptions(opts)
+
+	return paginate(gc, &opts.ListOptions, func() ([]*g.PullRequest, *g.Response, error) {
+		return gc.client.PullRequests.List(gc.Context, owner, repo, opts)
+	}, f)
+}
+
+// EachCommit calls f for every commit of the repository matching the options
+func (gc *GitHubClient) EachCommit(owner, repo string, opts *g.CommitsListOptions, f func(*g.RepositoryCommit) error) error {
+	opts = copyOptions(opts)
+
+	return paginate(gc, &opts.ListOptions, func() ([]*g.RepositoryCommit, *g.Response, error) {
+		return gc.client.Repositories.ListCommits(gc.Context, owner, repo, opts)
+	}, f)
+}
+
+// EachComment calls f for every comment on an issue or pull request
+func (gc *GitHubClient) EachComment(owner, repo string, number int, f func(*g.IssueComment) error) error {
+	opts := &g.IssueListCommentsOptions{}
+
+	return paginate(gc, &opts.ListOptions, func() ([]*g.IssueComment, *g.Response, error) {
+		return gc.client.Issues.ListComments(gc.Context, owner, repo, number, opts)
+	}, f)
+}
+
+// EachOrgRepository calls f for every repository of the organization
+func (gc *GitHubClient) EachOrgRepository(org string, opts *g.RepositoryListByOrgOptions, f func(*g.Repository) error) error {
+	opts = copyOptions(opts)
+
+	return paginate(gc, &opts.ListOptions, func() ([]*g.Repository, *g.Response, error) {
+		return gc.client.Repositories.ListByOrg(gc.Context, org, opts)
+	}, f)
+}
+
+// EachUserRepository calls f for every repository of the user
+func (gc *GitHubClient) EachUserRepository(user string, opts *g.RepositoryListByUserOptions, f func(*g.Repository) error) error {
+	opts = copyOptions(opts)
+
+	return paginate(gc, &opts.ListOptions, func() ([]*g.Repository, *g.Response, error) {
+		return gc.client.Repositories.ListByUser(gc.Context, user, opts)
+	}, f)
+}
@@ -0,0 +1,44 @@
+package github
+
+import (
+	"fmt"
+	"net/http"
+	"testing"
+
+	g "github.com/google/go-github/v63/github"
+)
+
+func TestEachIssue(t *testing.T) {
+	gc := newTestClient(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		page := r.URL.Query().Get("page")
+		if page == "" {
+			page = "1"
+		}
+		if page == "1" {
+			w.Header().Set("Link", fmt.Sprintf(`<http://%s%s?page=2>; rel="next"`, r.Host, r.URL.Path))
+		}
+		fmt.Fprintf(w, `[{"number": %s}]`, page)
+	}))
+
+	opts := &g.IssueListByRepoOptions{State: "open"}
+
+	// The same options list every page on each call
+	for i := 0; i < 2; i++ {
+		var numbers []int
+		err := gc.EachIssue("owner", "repo", opts, func(issue *g.Issue) error {
+			numbers = append(numbers, issue.GetNumber())
+			return nil
+		})
+		if err != nil {
+			t.Fatalf("EachIssue: %v", err)
+		}
+
+		if len(numbers) != 2 || numbers[0] != 1 || numbers[1] != 2 {
+			t.Errorf("issues = %v, want [1 2]", numbers)
+		}
+	}
+
+	if opts.Page != 0 || opts.PerPage != 0 {
+		t.Errorf("options changed to page %d of %d", opts.Page, opts.PerPage)
+	}
+}
@@ -0,0 +1,42 @@
+package github
+
+import (
+	g "github.com/google/go-github/v63/github"
+)
+
+func (gc *GitHubClient) CreatePullRequest(owner, repo string, pull *g.NewPullRequest) (*g.PullRequest, error) {
+	pr, _, err := gc.client.PullRequests.Create(gc.Context, owner, repo, pull)
+	if err != nil {
+		return nil, err
+	}
+
+	return pr, nil
+}
+
+func (gc *GitHubClient) GetPullRequest(owner, repo string, number int) (*g.PullRequest, error) {
+	pr, _, err := gc.client.PullRequests.Get(gc.Context, owner, repo, number)
+	if err != nil {
+		return nil, err
+	}
+
+	return pr, nil
+}
+
+// CreateReviewComment comments on a line of the pull request diff
+func (gc *GitHubClient) CreateReviewComment(owner, repo string, number int, comment *g.PullRequestComment) (*g.PullRequestComment, error) {
+	result, _, err := gc.client.PullRequests.CreateComment(gc.Context, owner, repo, number, comment)
+	if err != nil {
+		return nil, err
+	}
+
+	return result, nil
+}
+
+func (gc *GitHubClient) CreateReview(owner, repo string, number int, review *g.PullRequestReviewRequest) (*g.PullRequestReview, error) {
+	result, _, err := gc.client.PullRequests.CreateReview(gc.Context, owner, repo, number, review)
+	if err != nil {
+		return nil, err
+	}
+
+	return result, nil
+}
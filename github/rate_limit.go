@@ -0,0 +1,305 @@
+package github
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"math/rand"
+	"net/http"
+	"net/http/httputil"
+	"strconv"
+	"sync"
+	"time"
+)
+
+const (
+	headerRateLimit     = "X-RateLimit-Limit"
+	headerRateRemaining = "X-RateLimit-Remaining"
+	headerRateReset     = "X-RateLimit-Reset"
+	headerRetryAfter    = "Retry-After"
+
+	maxCachedResponses = 1000
+	// maxCachedBody skips caching larger responses and maxCacheBytes bounds
+	// the size of all the cached responses
+	maxCachedBody = 1 << 20
+	maxCacheBytes = 32 << 20
+)
+
+// idempotent are the methods retried after server errors
+var idempotent = map[string]bool{
+	http.MethodGet:     true,
+	http.MethodHead:    true,
+	http.MethodPut:     true,
+	http.MethodDelete:  true,
+	http.MethodOptions: true,
+}
+
+// RateLimit is the last primary rate limit reported by GitHub
+type RateLimit struct {
+	Limit     int
+	Remaining int
+	Reset     time.Time
+}
+
+// rateLimitTransport tracks rate limits, waits for them to reset, retries
+// transient errors and serves unchanged GET responses from an ETag cache.
+type rateLimitTransport struct {
+	base         http.RoundTripper
+	maxRetries   int
+	maxWait      time.Duration
+	cacheEnabled bool
+
+	lock       sync.RWMutex
+	rateLimit  *RateLimit
+	cache      map[string]*cachedResponse
+	cacheBytes int
+}
+
+// cachedResponse is a serialized response kept for conditional requests
+type cachedResponse struct {
+	etag string
+	dump []byte
+}
+
+func newRateLimitTransport(base http.RoundTripper, config *GitHubClientConfig) *rateLimitTransport {
+	return &rateLimitTransport{
+		base:         base,
+		maxRetries:   config.MaxRetries,
+		maxWait:      config.MaxRateLimitWait,
+		cacheEnabled: !config.DisableCache,
+		cache:        make(map[string]*cachedResponse),
+	}
+}
+
+func (t *rateLimitTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	key := ""
+	var cached *cachedResponse
+	if t.cacheEnabled && req.Method == http.MethodGet {
+		key = req.URL.String() + " " + req.Header.Get("Accept")
+		cached = t.cached(key)
+	}
+
+	for attempt := 0; ; attempt++ {
+		r := req
+		if attempt > 0 || cached != nil {
+			var err error
+			if r, err = cloneRequest(req); err != nil {
+				return nil, err
+			}
+		}
+
+		if cached != nil {
+			r.Header.Set("If-None-Match", cached.etag)
+		}
+
+		resp, err := t.base.RoundTrip(r)
+		if err != nil {
+			return nil, err
+		}
+
+		t.updateRateLimit(resp)
+
+		if resp.StatusCode == http.StatusNotModified && cached != nil {
+			resp.Body.Close()
+			return cached.response(req, resp.Header)
+		}
+
+		wait, retry := t.retryAfter(req, resp, attempt)
+		if !retry {
+			if key != "" && resp.StatusCode == http.StatusOK && resp.Header.Get("ETag") != "" {
+				t.store(key, resp)
+			}
+			return resp, nil
+		}
+
+		resp.Body.Close()
+
+		select {
+		case <-req.Context().Done():
+			return nil, req.Context().Err()
+		case <-time.After(wait):
+		}
+	}
+}
+
+// retryAfter reports whether the response should be retried and how long to
+// wait before doing so
+func (t *rateLimitTransport) retryAfter(req *http.Request, resp *http.Response, attempt int) (time.Duration, bool) {
+	switch {
+	case resp.StatusCode == http.StatusForbidden || resp.StatusCode == http.StatusTooManyRequests:
+		// Secondary rate limits report how long to wait
+		if s := resp.Header.Get(headerRetryAfter); s != "" {
+			seconds, err := strconv.Atoi(s)
+			if err != nil {
+				return 0, false
+			}
+			wait := time.Duration(seconds) * time.Second
+			return wait, wait <= t.maxWait && attempt < t.maxRetries
+		}
+
+		// Primary rate limits are exhausted until the reset time
+		if resp.Header.Get(headerRateRemaining) == "0" {
+			reset, err := strconv.ParseInt(resp.Header.Get(headerRateReset), 10, 64)
+			if err != nil {
+				return 0, false
+			}
+			wait := time.Until(time.Unix(reset, 0)) + time.Second
+			return wait, wait <= t.maxWait && attempt < t.maxRetries
+		}
+
+		return 0, false
+	case resp.StatusCode >= 500:
+		// The request may have been applied, only idempotent ones are retried
+		if attempt >= t.maxRetries || !idempotent[req.Method] {
+			return 0, false
+		}
+		// Exponential backoff from 500ms with up to 50% jitter
+		backoff := 500 * time.Millisecond << attempt
+		return backoff + time.Duration(rand.Int63n(int64(backoff/2))), true
+	}
+
+	return 0, false
+}
+
+func (t *rateLimitTransport) updateRateLimit(resp *http.Response) {
+	remaining := resp.Header.Get(headerRateRemaining)
+	if remaining == "" {
+		return
+	}
+
+	rl := &RateLimit{}
+	rl.Remaining, _ = strconv.Atoi(remaining)
+	rl.Limit, _ = strconv.Atoi(resp.Header.Get(headerRateLimit))
+	if reset, err := strconv.ParseInt(resp.Header.Get(headerRateReset), 10, 64); err == nil {
+		rl.Reset = time.Unix(reset, 0)
+	}
+
+	t.lock.Lock()
+	t.rateLimit = rl
+	t.lock.Unlock()
+}
+
+func (t *rateLimitTransport) cached(key string) *cachedResponse {
+	t.lock.RLock()
+	defer t.lock.RUnlock()
+
+	return t.cache[key]
+}
+
+// store keeps a copy of the response and replaces its body so it can still
+// be read by the caller. Responses larger than maxCachedBody aren't kept.
+func (t *rateLimitTransport) store(key string, resp *http.Response) {
+	if resp.ContentLength > maxCachedBody {
+		return
+	}
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxCachedBody+1))
+	if err != nil || len(body) > maxCachedBody {
+		// The caller reads what was buffered followed by the rest
+		resp.Body = struct {
+			io.Reader
+			io.Closer
+		}{io.MultiReader(bytes.NewReader(body), resp.Body), resp.Body}
+		return
+	}
+	resp.Body.Close()
+	resp.Body = io.NopCloser(bytes.NewReader(body))
+
+	dump, err := httputil.DumpResponse(&http.Response{
+		Status:     resp.Status,
+		StatusCode: resp.StatusCode,
+		Proto:      resp.Proto,
+		ProtoMajor: resp.ProtoMajor,
+		ProtoMinor: resp.ProtoMinor,
+		Header:     resp.Header.Clone(),
+		Body:       io.NopCloser(bytes.NewReader(body)),
+	}, true)
+	if err != nil {
+		return
+	}
+
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	if old, ok := t.cache[key]; ok {
+		t.cacheBytes -= len(old.dump)
+		delete(t.cache, key)
+	}
+
+	// Drop arbitrary entries to bound the cache size
+	for k, c := range t.cache {
+		if len(t.cache) < maxCachedResponses && t.cacheBytes+len(dump) <= maxCacheBytes {
+			break
+		}
+		t.cacheBytes -= len(c.dump)
+		delete(t.cache, k)
+	}
+
+	t.cache[key] = &cachedResponse{
+		etag: resp.Header.Get("ETag"),
+		dump: dump,
+	}
+	t.cacheBytes += len(dump)
+}
+
+// response rebuilds the cached response, carrying the rate limit headers of
+// the not modified response so they stay current
+func (c *cachedResponse) response(req *http.Request, header http.Header) (*http.Response, error) {
+	resp, err := http.ReadResponse(bufio.NewReader(bytes.NewReader(c.dump)), req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read cached response: %v", err)
+	}
+
+	for _, h := range []string{headerRateLimit, headerRateRemaining, headerRateReset} {
+		if v := header.Get(h); v != "" {
+			resp.Header.Set(h, v)
+		}
+	}
+
+	return resp, nil
+}
+
+func cloneRequest(req *http.Request) (*http.Request, error) {
+	r := req.Clone(req.Context())
+	if req.Body != nil && req.GetBody != nil {
+		body, err := req.GetBody()
+		if err != nil {
+			return nil, err
+		}
+		r.Body = body
+	}
+
+	return r, nil
+}
+
+// RateLimit returns the last rate limit reported by GitHub, or nil when no
+// request was made yet
+func (gc *GitHubClient) RateLimit() *RateLimit {
+	gc.rateLimits.lock.RLock()
+	defer gc.rateLimits.lock.RUnlock()
+
+	if gc.rateLimits.rateLimit == nil {
+		return nil
+	}
+
+	rl := *gc.rateLimits.rateLimit
+	return &rl
+}
+
+// RateLimitHook returns a readiness hook failing when fewer than
+// minRemaining requests are left before the rate limit resets
+func (gc *GitHubClient) RateLimitHook(minRemaining int) func() error {
+	return func() error {
+		rl := gc.RateLimit()
+		if rl == nil || time.Now().After(rl.Reset) {
+			return nil
+		}
+
+		if rl.Remaining < minRemaining {
+			return fmt.Errorf("github rate limit low: %d of %d requests remaining until %s", rl.Remaining, rl.Limit, rl.Reset.Format(time.RFC3339))
+		}
+
+		return nil
+	}
+}
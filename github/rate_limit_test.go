@@ -0,0 +1,170 @@
+package github
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// testResponse is a response written by the test server
+type testResponse struct {
+	status int
+	header map[string]string
+}
+
+func TestRateLimitTransportRetries(t *testing.T) {
+	tests := []struct {
+		name      string
+		method    string
+		responses []testResponse
+		// wantRequests is the number of requests the server receives
+		wantRequests int
+		wantStatus   int
+	}{
+		{
+			name:   "retry after secondary rate limit",
+			method: http.MethodGet,
+			responses: []testResponse{
+				{status: http.StatusTooManyRequests, header: map[string]string{headerRetryAfter: "0"}},
+				{status: http.StatusOK},
+			},
+			wantRequests: 2,
+			wantStatus:   http.StatusOK,
+		},
+		{
+			name:   "retry after longer than the maximum wait",
+			method: http.MethodGet,
+			responses: []testResponse{
+				{status: http.StatusTooManyRequests, header: map[string]string{headerRetryAfter: "3600"}},
+			},
+			wantRequests: 1,
+			wantStatus:   http.StatusTooManyRequests,
+		},
+		{
+			name:   "wait for primary rate limit reset",
+			method: http.MethodGet,
+			responses: []testResponse{
+				{status: http.StatusForbidden, header: map[string]string{
+					headerRateRemaining: "0",
+					headerRateReset:     strconv.FormatInt(time.Now().Unix(), 10),
+				}},
+				{status: http.StatusOK},
+			},
+			wantRequests: 2,
+			wantStatus:   http.StatusOK,
+		},
+		{
+			name:   "server error retried on GET",
+			method: http.MethodGet,
+			responses: []testResponse{
+				{status: http.StatusBadGateway},
+				{status: http.StatusOK},
+			},
+			wantRequests: 2,
+			wantStatus:   http.StatusOK,
+		},
+		{
+			name:   "server error not retried on POST",
+			method: http.MethodPost,
+			responses: []testResponse{
+				{status: http.StatusBadGateway},
+				{status: http.StatusOK},
+			},
+			wantRequests: 1,
+			wantStatus:   http.StatusBadGateway,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var requests atomic.Int32
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				n := int(requests.Add(1))
+				resp := tt.responses[min(n, len(tt.responses))-1]
+				for k, v := range resp.header {
+					w.Header().Set(k, v)
+				}
+				w.WriteHeader(resp.status)
+			}))
+			defer srv.Close()
+
+			client := &http.Client{Transport: newRateLimitTransport(http.DefaultTransport, &GitHubClientConfig{
+				MaxRetries:       3,
+				MaxRateLimitWait: time.Minute,
+			})}
+
+			req, _ := http.NewRequest(tt.method, srv.URL, strings.NewReader("{}"))
+			resp, err := client.Do(req)
+			if err != nil {
+				t.Fatalf("request failed: %v", err)
+			}
+			resp.Body.Close()
+
+			if resp.StatusCode != tt.wantStatus {
+				t.Errorf("status = %d, want %d", resp.StatusCode, tt.wantStatus)
+			}
+			if got := int(requests.Load()); got != tt.wantRequests {
+				t.Errorf("server received %d requests, want %d", got, tt.wantRequests)
+			}
+		})
+	}
+}
+
+func TestRateLimitTransportCache(t *testing.T) {
+	tests := []struct {
+		name       string
+		body       string
+		wantCached bool
+	}{
+		{
+			name:       "not modified response served from the cache",
+			body:       `{"number": 1}`,
+			wantCached: true,
+		},
+		{
+			name: "large response not cached",
+			body: strings.Repeat("a", maxCachedBody+1),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var notModified atomic.Int32
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set(headerRateRemaining, "10")
+				if r.Header.Get("If-None-Match") == `"etag"` {
+					notModified.Add(1)
+					w.WriteHeader(http.StatusNotModified)
+					return
+				}
+				w.Header().Set("ETag", `"etag"`)
+				io.WriteString(w, tt.body)
+			}))
+			defer srv.Close()
+
+			client := &http.Client{Transport: newRateLimitTransport(http.DefaultTransport, &GitHubClientConfig{})}
+
+			for i := 0; i < 2; i++ {
+				resp, err := client.Get(srv.URL)
+				if err != nil {
+					t.Fatalf("request failed: %v", err)
+				}
+				body, _ := io.ReadAll(resp.Body)
+				resp.Body.Close()
+
+				if resp.StatusCode != http.StatusOK || string(body) != tt.body {
+					t.Fatalf("request %d: status %d with a body of %d bytes, want 200 with %d bytes", i, resp.StatusCode, len(body), len(tt.body))
+				}
+			}
+
+			if cached := notModified.Load() == 1; cached != tt.wantCached {
+				t.Errorf("served from the cache = %v, want %v", cached, tt.wantCached)
+			}
+		})
+	}
+}
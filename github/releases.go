@@ -0,0 +1,32 @@
+package github
+
+import (
+	g "github.com/google/go-github/v63/github"
+)
+
+func (gc *GitHubClient) CreateRelease(owner, repo string, release *g.RepositoryRelease) (*g.RepositoryRelease, error) {
+	result, _, err := gc.client.Repositories.CreateRelease(gc.Context, owner, repo, release)
+	if err != nil {
+		return nil, err
+	}
+
+	return result, nil
+}
+
+func (gc *GitHubClient) GetLatestRelease(owner, repo string) (*g.RepositoryRelease, error) {
+	result, _, err := gc.client.Repositories.GetLatestRelease(gc.Context, owner, repo)
+	if err != nil {
+		return nil, err
+	}
+
+	return result, nil
+}
+
+func (gc *GitHubClient) GetReleaseByTag(owner, repo, tag string) (*g.RepositoryRelease, error) {
+	result, _, err := gc.client.Repositories.GetReleaseByTag(gc.Context, owner, repo, tag)
+	if err != nil {
+		return nil, err
+	}
+
+	return result, nil
+}
@@ -0,0 +1,151 @@
+package github
+
+import (
+	"fmt"
+	"mime"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/fandujar/golaze"
+	"github.com/go-chi/chi/v5"
+	g "github.com/google/go-github/v63/github"
+	"github.com/rs/zerolog/log"
+)
+
+// WebhookTopicPrefix prefixes the event bus topic of webhook events, e.g.
+// github.push or github.pull_request
+const WebhookTopicPrefix = "github."
+
+// maxWebhookPayload is the maximum payload size GitHub delivers
+const maxWebhookPayload = 25 << 20
+
+type WebhookConfig struct {
+	// Secret is the shared secret used to validate X-Hub-Signature-256
+	Secret   string
+	EventBus *golaze.EventBus
+	// DeduplicationWindow is how long delivery IDs are remembered to drop
+	// redeliveries. Defaults to one hour.
+	DeduplicationWindow time.Duration
+}
+
+// Webhook receives GitHub webhooks and publishes them on the event bus as
+// go-github event types.
+type Webhook struct {
+	*WebhookConfig
+
+	lock       sync.Mutex
+	deliveries map[string]time.Time
+}
+
+func NewWebhook(config *WebhookConfig) (*Webhook, error) {
+	if config.Secret == "" {
+		return nil, fmt.Errorf("webhook requires a secret")
+	}
+
+	if config.EventBus == nil {
+		return nil, fmt.Errorf("webhook requires an event bus")
+	}
+
+	if config.DeduplicationWindow == 0 {
+		config.DeduplicationWindow = time.Hour
+	}
+
+	return &Webhook{
+		WebhookConfig: config,
+		deliveries:    make(map[string]time.Time),
+	}, nil
+}
+
+// Mount registers the webhook handler for POST requests on the pattern
+func (wh *Webhook) Mount(router chi.Router, pattern string) {
+	router.Post(pattern, wh.ServeHTTP)
+}
+
+func (wh *Webhook) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	signature := r.Header.Get(g.SHA256SignatureHeader)
+	if signature == "" {
+		golaze.JSONError(w, "missing signature", http.StatusUnauthorized)
+		return
+	}
+
+	contentType, _, err := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if err != nil {
+		golaze.JSONError(w, "invalid content type", http.StatusBadRequest)
+		return
+	}
+
+	body := http.MaxBytesReader(w, r.Body, maxWebhookPayload)
+	payload, err := g.ValidatePayloadFromBody(contentType, body, signature, []byte(wh.Secret))
+	if err != nil {
+		log.Error().Err(err).Msg("invalid webhook payload")
+		golaze.JSONError(w, "invalid signature", http.StatusUnauthorized)
+		return
+	}
+
+	eventType := g.WebHookType(r)
+	deliveryID := g.DeliveryID(r)
+
+	if eventType == "ping" {
+		golaze.JSONResponse(w, map[string]string{"status": "ok"}, http.StatusOK)
+		return
+	}
+
+	// GitHub disables hooks failing too often, events go-github doesn't know
+	// are acknowledged and dropped
+	if g.EventForType(eventType) == nil {
+		log.Debug().Msgf("ignoring unsupported webhook event %s", eventType)
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+
+	data, err := g.ParseWebHook(eventType, payload)
+	if err != nil {
+		log.Error().Err(err).Msgf("failed to parse webhook %s", deliveryID)
+		golaze.JSONError(w, "invalid payload", http.StatusBadRequest)
+		return
+	}
+
+	if wh.seen(deliveryID) {
+		log.Info().Msgf("duplicate webhook delivery %s", deliveryID)
+		golaze.JSONResponse(w, map[string]string{"status": "duplicate"}, http.StatusOK)
+		return
+	}
+
+	event := golaze.NewEvent(r.Context(), data)
+	if deliveryID != "" {
+		event.ID = deliveryID
+	}
+	event.Headers[g.EventTypeHeader] = eventType
+	event.Headers[g.DeliveryIDHeader] = deliveryID
+
+	wh.EventBus.Publish(WebhookTopicPrefix+eventType, event)
+
+	golaze.JSONResponse(w, map[string]string{"status": "accepted"}, http.StatusAccepted)
+}
+
+// seen records the delivery ID and reports whether it was already received
+// within the deduplication window
+func (wh *Webhook) seen(deliveryID string) bool {
+	if deliveryID == "" {
+		return false
+	}
+
+	wh.lock.Lock()
+	defer wh.lock.Unlock()
+
+	now := time.Now()
+	for id, t := range wh.deliveries {
+		if now.Sub(t) > wh.DeduplicationWindow {
+			delete(wh.deliveries, id)
+		}
+	}
+
+	if _, ok := wh.deliveries[deliveryID]; ok {
+		return true
+	}
+
+	wh.deliveries[deliveryID] = now
+
+	return false
+}
@@ -0,0 +1,175 @@
+package github
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/fandujar/golaze"
+	g "github.com/google/go-github/v63/github"
+)
+
+const testWebhookSecret = "secret"
+
+func sign(payload string) string {
+	mac := hmac.New(sha256.New, []byte(testWebhookSecret))
+	mac.Write([]byte(payload))
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+func TestWebhook(t *testing.T) {
+	pushPayload := `{"ref": "refs/heads/main"}`
+
+	tests := []struct {
+		name        string
+		eventType   string
+		payload     string
+		signature   string
+		contentType string
+		wantStatus  int
+		wantTopic   string
+	}{
+		{
+			name:       "push",
+			eventType:  "push",
+			payload:    pushPayload,
+			signature:  sign(pushPayload),
+			wantStatus: http.StatusAccepted,
+			wantTopic:  WebhookTopicPrefix + "push",
+		},
+		{
+			name:       "ping",
+			eventType:  "ping",
+			payload:    `{"zen": "ok"}`,
+			signature:  sign(`{"zen": "ok"}`),
+			wantStatus: http.StatusOK,
+		},
+		{
+			name:       "unsupported event",
+			eventType:  "unknown_event",
+			payload:    `{}`,
+			signature:  sign(`{}`),
+			wantStatus: http.StatusNoContent,
+		},
+		{
+			name:       "invalid payload",
+			eventType:  "push",
+			payload:    `not json`,
+			signature:  sign(`not json`),
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name:       "missing signature",
+			eventType:  "push",
+			payload:    pushPayload,
+			wantStatus: http.StatusUnauthorized,
+		},
+		{
+			name:       "invalid signature",
+			eventType:  "push",
+			payload:    pushPayload,
+			signature:  sign("other payload"),
+			wantStatus: http.StatusUnauthorized,
+		},
+		{
+			name:        "invalid content type",
+			eventType:   "push",
+			payload:     pushPayload,
+			signature:   sign(pushPayload),
+			contentType: ";",
+			wantStatus:  http.StatusBadRequest,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			bus := golaze.NewEventBus(&golaze.EventBusConfig{})
+			received := make(chan *golaze.Event, 1)
+			if tt.wantTopic != "" {
+				bus.SubscribeFunc(tt.wantTopic, func(event *golaze.Event) error {
+					received <- event
+					return nil
+				})
+			}
+
+			wh, err := NewWebhook(&WebhookConfig{
+				Secret:   testWebhookSecret,
+				EventBus: bus,
+			})
+			if err != nil {
+				t.Fatalf("NewWebhook: %v", err)
+			}
+
+			contentType := tt.contentType
+			if contentType == "" {
+				contentType = "application/json"
+			}
+
+			req := httptest.NewRequest(http.MethodPost, "/webhook", strings.NewReader(tt.payload))
+			req.Header.Set("Content-Type", contentType)
+			req.Header.Set(g.EventTypeHeader, tt.eventType)
+			req.Header.Set(g.DeliveryIDHeader, "delivery-1")
+			if tt.signature != "" {
+				req.Header.Set(g.SHA256SignatureHeader, tt.signature)
+			}
+
+			rec := httptest.NewRecorder()
+			wh.ServeHTTP(rec, req)
+
+			if rec.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d", rec.Code, tt.wantStatus)
+			}
+
+			if tt.wantTopic == "" {
+				return
+			}
+
+			select {
+			case event := <-received:
+				if event.ID != "delivery-1" {
+					t.Errorf("event ID = %q, want delivery-1", event.ID)
+				}
+				if got := event.Headers[g.EventTypeHeader]; got != tt.eventType {
+					t.Errorf("event type header = %q, want %q", got, tt.eventType)
+				}
+				if _, ok := event.Data.(*g.PushEvent); !ok {
+					t.Errorf("event data is %T, want *github.PushEvent", event.Data)
+				}
+			case <-time.After(time.Second):
+				t.Fatal("event not published")
+			}
+		})
+	}
+}
+
+func TestWebhookDeduplication(t *testing.T) {
+	bus := golaze.NewEventBus(&golaze.EventBusConfig{})
+	wh, err := NewWebhook(&WebhookConfig{
+		Secret:   testWebhookSecret,
+		EventBus: bus,
+	})
+	if err != nil {
+		t.Fatalf("NewWebhook: %v", err)
+	}
+
+	payload := `{"ref": "refs/heads/main"}`
+	for _, want := range []int{http.StatusAccepted, http.StatusOK} {
+		req := httptest.NewRequest(http.MethodPost, "/webhook", strings.NewReader(payload))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set(g.EventTypeHeader, "push")
+		req.Header.Set(g.DeliveryIDHeader, "delivery-1")
+		req.Header.Set(g.SHA256SignatureHeader, sign(payload))
+
+		rec := httptest.NewRecorder()
+		wh.ServeHTTP(rec, req)
+
+		if rec.Code != want {
+			t.Errorf("status = %d, want %d", rec.Code, want)
+		}
+	}
+}
@@ -1,49 +1,57 @@
 package golaze
 
 import (
+	"context"
+	"net"
 	"net/http"
 	"os"
+	"sync"
 
 	"github.com/go-chi/chi/v5"
+	"github.com/rs/zerolog/log"
 )
 
 type HealthCheckConfig struct {
-	Port           string
+	Port string `env:"HEALTHCHECK_PORT" flag:"healthcheck-port" usage:"health check port"`
+	// Address is the host and port listened on, overriding Port
+	Address        string `env:"HEALTHCHECK_ADDRESS" flag:"healthcheck-address" usage:"health check listen address, overrides the port"`
 	LivenessHooks  []func() error
 	ReadinessHooks []func() error
 	Router         *chi.Mux
+	ServerConfig   `yaml:",inline"`
 }
 
 type HealthCheck struct {
 	*HealthCheckConfig
+	lock   sync.RWMutex
+	server httpServer
 }
 
 func LivenessHandler(hooks ...func() error) func(w http.ResponseWriter, r *http.Request) {
-	errors := make(chan error, len(hooks))
-	// Run all the hooks concurrently
-	for _, hook := range hooks {
-		go func(hook func() error) {
-			errors <- hook()
-		}(hook)
-	}
-
-	// Wait for all the hooks to finish
-	for i := 0; i < len(hooks); i++ {
-		if err := <-errors; err != nil {
-			return func(w http.ResponseWriter, r *http.Request) {
-				JSONError(w, "error", http.StatusInternalServerError)
-			}
+	return func(w http.ResponseWriter, r *http.Request) {
+		if err := runHooks(hooks...); err != nil {
+			JSONError(w, "error", http.StatusInternalServerError)
+			return
 		}
+
+		JSONResponse(w, map[string]string{"status": "ok"}, http.StatusOK)
 	}
+}
 
+func ReadinessHandler(hooks ...func() error) func(w http.ResponseWriter, r *http.Request) {
 	return func(w http.ResponseWriter, r *http.Request) {
+		if err := runHooks(hooks...); err != nil {
+			JSONError(w, "error", http.StatusInternalServerError)
+			return
+		}
+
 		JSONResponse(w, map[string]string{"status": "ok"}, http.StatusOK)
 	}
 }
 
-func ReadinessHandler(hooks ...func() error) func(w http.ResponseWriter, r *http.Request) {
+// runHooks runs all the hooks concurrently and returns the first error
+func runHooks(hooks ...func() error) error {
 	errors := make(chan error, len(hooks))
-	// Run all the hooks concurrently
 	for _, hook := range hooks {
 		go func(hook func() error) {
 			errors <- hook()
@@ -51,17 +59,78 @@ func ReadinessHandler(hooks ...func() error) func(w http.ResponseWriter, r *http
 	}
 
 	// Wait for all the hooks to finish
+	var err error
 	for i := 0; i < len(hooks); i++ {
-		if err := <-errors; err != nil {
-			return func(w http.ResponseWriter, r *http.Request) {
-				JSONError(w, "error", http.StatusInternalServerError)
-			}
+		if hookErr := <-errors; hookErr != nil && err == nil {
+			err = hookErr
 		}
 	}
 
-	return func(w http.ResponseWriter, r *http.Request) {
-		JSONResponse(w, map[string]string{"status": "ok"}, http.StatusOK)
+	return err
+}
+
+// AddLivenessHook adds a hook checked by the liveness endpoint
+func (hc *HealthCheck) AddLivenessHook(hook func() error) {
+	hc.lock.Lock()
+	defer hc.lock.Unlock()
+
+	hc.LivenessHooks = append(hc.LivenessHooks, hook)
+}
+
+// AddReadinessHook adds a hook checked by the readiness endpoint
+func (hc *HealthCheck) AddReadinessHook(hook func() error) {
+	hc.lock.Lock()
+	defer hc.lock.Unlock()
+
+	hc.ReadinessHooks = append(hc.ReadinessHooks, hook)
+}
+
+func (hc *HealthCheck) hooks(hooks *[]func() error) []func() error {
+	hc.lock.RLock()
+	defer hc.lock.RUnlock()
+
+	return append([]func() error(nil), *hooks...)
+}
+
+func (hc *HealthCheck) Name() string {
+	return "health-check"
+}
+
+// Start starts the health check server
+func (hc *HealthCheck) Start(ctx context.Context) error {
+	if err := hc.server.start("health check", hc.newServer(hc.addr(), hc.Router), &hc.ServerConfig); err != nil {
+		return err
 	}
+
+	log.Info().Msgf("health check server listening on %s", hc.Addr())
+	return nil
+}
+
+func (hc *HealthCheck) handoffFiles() ([]*handoffFile, error) {
+	return handoffFiles(hc.server.handoff)
+}
+
+// Addr returns the address the health check server listens on, such as the
+// port picked when listening on port 0. It is nil until the server starts.
+func (hc *HealthCheck) Addr() net.Addr {
+	return hc.server.addr()
+}
+
+func (hc *HealthCheck) addr() string {
+	if hc.Address != "" {
+		return hc.Address
+	}
+	return ":" + hc.Port
+}
+
+// Stop gracefully shuts down the health check server
+func (hc *HealthCheck) Stop(ctx context.Context) error {
+	return hc.server.stop(ctx)
+}
+
+// Failed receives the error of the health check server failing while running
+func (hc *HealthCheck) Failed() <-chan error {
+	return hc.server.failed
 }
 
 func NewHealthCheck(config *HealthCheckConfig) *HealthCheck {
@@ -74,15 +143,21 @@ func NewHealthCheck(config *HealthCheckConfig) *HealthCheck {
 		}
 	}
 
+	hc := &HealthCheck{
+		HealthCheckConfig: config,
+	}
+
 	if config.Router == nil {
 		r := NewRouter()
-		r.Get("/liveness", LivenessHandler(config.LivenessHooks...))
-		r.Get("/readiness", ReadinessHandler(config.ReadinessHooks...))
+		r.Get("/liveness", func(w http.ResponseWriter, r *http.Request) {
+			LivenessHandler(hc.hooks(&hc.LivenessHooks)...)(w, r)
+		})
+		r.Get("/readiness", func(w http.ResponseWriter, r *http.Request) {
+			ReadinessHandler(hc.hooks(&hc.ReadinessHooks)...)(w, r)
+		})
 
 		config.Router = r
 	}
 
-	return &HealthCheck{
-		config,
-	}
+	return hc
 }
@@ -0,0 +1,189 @@
+package golaze
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog/log"
+)
+
+// ServerConfig configures the http.Server of the web app and health check.
+// Zero timeouts use the defaults, negative ones disable the timeout.
+type ServerConfig struct {
+	// ReadTimeout defaults to 5 seconds
+	ReadTimeout       time.Duration
+	ReadHeaderTimeout time.Duration
+	// WriteTimeout defaults to 5 seconds
+	WriteTimeout time.Duration
+	IdleTimeout  time.Duration
+	// MaxHeaderBytes defaults to 1MB
+	MaxHeaderBytes    int
+	DisableKeepAlives bool
+	// BaseContext and ConnState are set on the http.Server as is
+	BaseContext func(net.Listener) context.Context
+	ConnState   func(net.Conn, http.ConnState)
+	// Server replaces the server built from the settings above. Its Addr
+	// and Handler default to the component address and router.
+	Server *http.Server
+	// Listener is served instead of listening on the address
+	Listener net.Listener
+	// SocketMode sets the permissions of Unix domain sockets
+	SocketMode os.FileMode
+}
+
+// newServer returns the configured server for the address and handler
+func (c *ServerConfig) newServer(addr string, handler http.Handler) *http.Server {
+	if c.Server != nil {
+		if c.Server.Addr == "" {
+			c.Server.Addr = addr
+		}
+		if c.Server.Handler == nil {
+			c.Server.Handler = handler
+		}
+		return c.Server
+	}
+
+	maxHeaderBytes := c.MaxHeaderBytes
+	if maxHeaderBytes == 0 {
+		maxHeaderBytes = 1 << 20
+	}
+
+	server := &http.Server{
+		Addr:              addr,
+		Handler:           handler,
+		ReadTimeout:       serverTimeout(c.ReadTimeout, 5*time.Second),
+		ReadHeaderTimeout: serverTimeout(c.ReadHeaderTimeout, 0),
+		WriteTimeout:      serverTimeout(c.WriteTimeout, 5*time.Second),
+		IdleTimeout:       serverTimeout(c.IdleTimeout, 0),
+		MaxHeaderBytes:    maxHeaderBytes,
+		BaseContext:       c.BaseContext,
+		ConnState:         c.ConnState,
+	}
+
+	if c.DisableKeepAlives {
+		server.SetKeepAlivesEnabled(false)
+	}
+
+	return server
+}
+
+func serverTimeout(timeout, defaultTimeout time.Duration) time.Duration {
+	switch {
+	case timeout < 0:
+		return 0
+	case timeout == 0:
+		return defaultTimeout
+	default:
+		return timeout
+	}
+}
+
+// httpServer runs the http.Server of a component
+type httpServer struct {
+	name     string
+	server   *http.Server
+	failed   chan error
+	lock     sync.Mutex
+	listener net.Listener
+}
+
+// start listens as configured on the server address and serves in the
+// background, over TLS when the server has a TLS config
+func (s *httpServer) start(name string, server *http.Server, config *ServerConfig) error {
+	s.name = name
+
+	listener, err := config.listen(name, server.Addr)
+	if err != nil {
+		return fmt.Errorf("%s server failed to listen: %w", name, err)
+	}
+
+	// stop only shuts down servers that are listening
+	s.server = server
+	s.lock.Lock()
+	s.listener = listener
+	s.lock.Unlock()
+
+	if s.failed == nil {
+		s.failed = make(chan error, 1)
+	}
+
+	go func() {
+		var err error
+		if s.server.TLSConfig != nil {
+			// The certificate is provided by the TLS config
+			err = s.server.ServeTLS(listener, "", "")
+		} else {
+			err = s.server.Serve(listener)
+		}
+
+		if err != nil && err != http.ErrServerClosed {
+			log.Error().Err(err).Msgf("%s server failed", name)
+			select {
+			case s.failed <- fmt.Errorf("%s server failed: %w", name, err):
+			default:
+			}
+		}
+	}()
+
+	return nil
+}
+
+// addr returns the address listened on, nil until the server starts
+func (s *httpServer) addr() net.Addr {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	if s.listener == nil {
+		return nil
+	}
+	return s.listener.Addr()
+}
+
+// handoff returns a copy of the listener file for a restarted process
+func (s *httpServer) handoff() (*handoffFile, error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	if s.listener == nil {
+		return nil, nil
+	}
+
+	filer, ok := s.listener.(interface{ File() (*os.File, error) })
+	if !ok {
+		return nil, fmt.Errorf("%s server listener has no file", s.name)
+	}
+
+	f, err := filer.File()
+	if err != nil {
+		return nil, err
+	}
+
+	h := &handoffFile{
+		key:  listenerKey(s.name, s.server.Addr),
+		file: f,
+	}
+
+	if unixListener, ok := s.listener.(*net.UnixListener); ok {
+		h.unixListener = unixListener
+	}
+
+	return h, nil
+}
+
+func (s *httpServer) stop(ctx context.Context) error {
+	if s.server == nil {
+		return nil
+	}
+
+	err := s.server.Shutdown(ctx)
+
+	// Shutdown only closes the listener once Serve is running
+	s.listener.Close()
+
+	return err
+}
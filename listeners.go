@@ -0,0 +1,140 @@
+package golaze
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+const (
+	// UnixAddressPrefix prefixes the socket path of Unix domain socket
+	// addresses, e.g. unix:/run/app.sock
+	UnixAddressPrefix = "unix:"
+	// SystemdAddressPrefix prefixes the name or index of a socket passed by
+	// systemd socket activation, e.g. systemd:http or systemd:0
+	SystemdAddressPrefix = "systemd:"
+
+	// systemdListenFDsStart is the first file descriptor passed by systemd
+	systemdListenFDsStart = 3
+)
+
+// listen returns the listener handed by the previous process on graceful
+// restarts, the configured listener, or listens on the TCP, Unix socket or
+// systemd address
+func (c *ServerConfig) listen(name, addr string) (net.Listener, error) {
+	if f := inheritedFile(listenerKey(name, addr)); f != nil {
+		defer f.Close()
+
+		listener, err := net.FileListener(f)
+		if unixListener, ok := listener.(*net.UnixListener); ok {
+			unixListener.SetUnlinkOnClose(true)
+		}
+		return listener, err
+	}
+
+	if c.Listener != nil {
+		return c.Listener, nil
+	}
+
+	switch {
+	case strings.HasPrefix(addr, UnixAddressPrefix):
+		return listenUnix(strings.TrimPrefix(addr, UnixAddressPrefix), c.SocketMode)
+	case strings.HasPrefix(addr, SystemdAddressPrefix):
+		return listenSystemd(strings.TrimPrefix(addr, SystemdAddressPrefix))
+	default:
+		return net.Listen("tcp", addr)
+	}
+}
+
+func listenUnix(path string, mode os.FileMode) (net.Listener, error) {
+	// A socket left by a previous process that didn't stop cleanly
+	if info, err := os.Stat(path); err == nil && info.Mode()&os.ModeSocket != 0 {
+		os.Remove(path)
+	}
+
+	listener, err := net.Listen("unix", path)
+	if err != nil {
+		return nil, err
+	}
+
+	if mode != 0 {
+		if err := os.Chmod(path, mode); err != nil {
+			listener.Close()
+			return nil, fmt.Errorf("failed to set socket permissions: %v", err)
+		}
+	}
+
+	return listener, nil
+}
+
+var (
+	systemdOnce  sync.Once
+	systemdCount int
+	systemdNames []string
+)
+
+// systemdSockets reads the sockets passed by systemd once, unsetting the
+// variables so child processes don't take them for their own
+func systemdSockets() (int, []string) {
+	systemdOnce.Do(func() {
+		defer func() {
+			os.Unsetenv("LISTEN_PID")
+			os.Unsetenv("LISTEN_FDS")
+			os.Unsetenv("LISTEN_FDNAMES")
+		}()
+
+		if pid, _ := strconv.Atoi(os.Getenv("LISTEN_PID")); pid != os.Getpid() {
+			return
+		}
+
+		count, err := strconv.Atoi(os.Getenv("LISTEN_FDS"))
+		if err != nil || count < 1 {
+			return
+		}
+
+		systemdCount = count
+		systemdNames = strings.Split(os.Getenv("LISTEN_FDNAMES"), ":")
+	})
+
+	return systemdCount, systemdNames
+}
+
+// listenSystemd returns the socket passed by systemd with the name given in
+// FileDescriptorName, or at the index. An empty name is the first socket.
+func listenSystemd(name string) (net.Listener, error) {
+	count, names := systemdSockets()
+	if count == 0 {
+		return nil, fmt.Errorf("no sockets passed by systemd")
+	}
+
+	index := -1
+	if name == "" {
+		index = 0
+	} else if i, err := strconv.Atoi(name); err == nil {
+		index = i
+	} else {
+		for i, n := range names {
+			if n == name {
+				index = i
+				break
+			}
+		}
+	}
+
+	if index < 0 || index >= count {
+		return nil, fmt.Errorf("systemd socket %s not found", name)
+	}
+
+	f := os.NewFile(uintptr(systemdListenFDsStart+index), "systemd:"+name)
+	defer f.Close()
+
+	listener, err := net.FileListener(f)
+	if err != nil {
+		return nil, fmt.Errorf("systemd socket %s is not a listener: %v", name, err)
+	}
+
+	return listener, nil
+}
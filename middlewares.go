@@ -6,6 +6,7 @@ import (
 
 	"github.com/go-chi/chi/v5"
 	"github.com/go-chi/chi/v5/middleware"
+	"github.com/google/uuid"
 	"github.com/rs/zerolog/log"
 )
 
@@ -47,6 +48,29 @@ func NewAuthMiddleware(getAuthTokens func() ([]*AuthToken, error)) func(http.Han
 	}
 }
 
+// CorrelationMiddleware stores the request correlation ID in the request
+// context, reusing the X-Correlation-ID or X-Request-ID header when present and
+// generating a new one otherwise. The ID is echoed back in the response.
+func CorrelationMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id := r.Header.Get(CorrelationIDHeader)
+		if id == "" {
+			id = r.Header.Get(middleware.RequestIDHeader)
+		}
+		if id == "" {
+			id = uuid.NewString()
+		}
+
+		ctx := ContextWithCorrelationID(r.Context(), id)
+		if causationID := r.Header.Get(CausationIDHeader); causationID != "" {
+			ctx = ContextWithCausationID(ctx, causationID)
+		}
+
+		w.Header().Set(CorrelationIDHeader, id)
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
 func LogMiddleware(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		ww := middleware.NewWrapResponseWriter(w, r.ProtoMajor)
@@ -56,6 +80,6 @@ func LogMiddleware(next http.Handler) http.Handler {
 		host := r.Header.Get("Host")
 		url := r.URL.String()
 
-		log.Info().Msgf("request: %v | method: %v | host: %v | status: %v | route pattern: %v", url, r.Method, host, ww.Status(), rctx.RoutePatterns)
+		log.Info().Str("correlation_id", CorrelationIDFromContext(r.Context())).Msgf("request: %v | method: %v | host: %v | status: %v | route pattern: %v", url, r.Method, host, ww.Status(), rctx.RoutePatterns)
 	})
 }
@@ -0,0 +1,165 @@
+package golaze
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+
+	"github.com/quic-go/quic-go"
+	"github.com/quic-go/quic-go/http3"
+	"github.com/rs/zerolog/log"
+	"golang.org/x/net/http2"
+	"golang.org/x/net/http2/h2c"
+)
+
+// HTTP2Config tunes HTTP/2, negotiated by default over TLS
+type HTTP2Config struct {
+	// H2C serves HTTP/2 over cleartext connections, e.g. between sidecars
+	H2C bool `env:"HTTP2_H2C" flag:"h2c" usage:"serve HTTP/2 over cleartext connections"`
+	// MaxConcurrentStreams limits the streams of a connection, also applied
+	// to HTTP/3. Zero uses the protocol defaults.
+	MaxConcurrentStreams uint32 `env:"HTTP2_MAX_CONCURRENT_STREAMS" flag:"http2-max-concurrent-streams" usage:"maximum concurrent streams per connection"`
+	MaxReadFrameSize     uint32 `env:"HTTP2_MAX_READ_FRAME_SIZE" flag:"http2-max-read-frame-size" usage:"maximum HTTP/2 frame size read"`
+}
+
+func (c *HTTP2Config) tuned() bool {
+	return c.MaxConcurrentStreams != 0 || c.MaxReadFrameSize != 0
+}
+
+// configure enables h2c and the HTTP/2 settings on the server
+func (c *HTTP2Config) configure(server *http.Server) error {
+	h2 := &http2.Server{
+		MaxConcurrentStreams: c.MaxConcurrentStreams,
+		MaxReadFrameSize:     c.MaxReadFrameSize,
+		IdleTimeout:          server.IdleTimeout,
+	}
+
+	if c.H2C {
+		server.Handler = h2c.NewHandler(server.Handler, h2)
+	}
+
+	// ConfigureServer applies the settings to TLS connections and registers
+	// the GOAWAY sent to h2c connections on shutdown, which the server no
+	// longer tracks once they are hijacked
+	if !c.H2C && (server.TLSConfig == nil || !c.tuned()) {
+		return nil
+	}
+
+	cleartext := server.TLSConfig == nil
+	if err := http2.ConfigureServer(server, h2); err != nil {
+		return fmt.Errorf("failed to configure HTTP/2: %v", err)
+	}
+
+	// ConfigureServer adds a TLS config, which would serve TLS
+	if cleartext {
+		server.TLSConfig = nil
+	}
+
+	return nil
+}
+
+// http3Server serves HTTP/3 over QUIC on the UDP port of the TLS server
+type http3Server struct {
+	addr   string
+	server *http3.Server
+	conn   net.PacketConn
+	failed chan error
+}
+
+func (s *http3Server) start(addr string, handler http.Handler, tlsConfig *tls.Config, maxStreams uint32, allow0RTT bool) error {
+	if tlsConfig == nil {
+		return errors.New("HTTP/3 requires TLS")
+	}
+
+	quicConfig := &quic.Config{
+		Allow0RTT: allow0RTT,
+	}
+	if maxStreams != 0 {
+		quicConfig.MaxIncomingStreams = int64(maxStreams)
+	}
+
+	conn, err := listenPacket(addr)
+	if err != nil {
+		return fmt.Errorf("HTTP/3 server failed to listen: %w", err)
+	}
+
+	s.addr = addr
+	s.conn = conn
+	s.server = &http3.Server{
+		Handler:    handler,
+		TLSConfig:  tlsConfig,
+		QUICConfig: quicConfig,
+	}
+
+	go func() {
+		if err := s.server.Serve(conn); err != nil && err != http.ErrServerClosed {
+			log.Error().Err(err).Msg("HTTP/3 server failed")
+			select {
+			case s.failed <- fmt.Errorf("HTTP/3 server failed: %w", err):
+			default:
+			}
+		}
+	}()
+
+	return nil
+}
+
+// listenPacket returns the connection handed by the previous process on
+// graceful restarts, or listens on the UDP address
+func listenPacket(addr string) (net.PacketConn, error) {
+	if f := inheritedFile(listenerKey("http3", addr)); f != nil {
+		defer f.Close()
+		return net.FilePacketConn(f)
+	}
+
+	return net.ListenPacket("udp", addr)
+}
+
+// handoff returns a copy of the connection file for a restarted process
+func (s *http3Server) handoff() (*handoffFile, error) {
+	if s.conn == nil {
+		return nil, nil
+	}
+
+	udpConn, ok := s.conn.(*net.UDPConn)
+	if !ok {
+		return nil, errors.New("HTTP/3 connection has no file")
+	}
+
+	f, err := udpConn.File()
+	if err != nil {
+		return nil, err
+	}
+
+	return &handoffFile{
+		key:  listenerKey("http3", s.addr),
+		file: f,
+	}, nil
+}
+
+// altSvc advertises HTTP/3 to the clients of the TCP server
+func (s *http3Server) altSvc(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if s.server != nil {
+			s.server.SetQUICHeaders(w.Header())
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+func (s *http3Server) stop(ctx context.Context) error {
+	if s.server == nil {
+		return nil
+	}
+
+	err := s.server.Shutdown(ctx)
+
+	// The server doesn't close the connection it serves
+	s.conn.Close()
+
+	return err
+}
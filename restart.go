@@ -0,0 +1,245 @@
+package golaze
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"os/exec"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog/log"
+)
+
+// inheritedListenersEnv lists the keys of the listeners handed to a restarted
+// process, passed as file descriptors from 3 in the same order
+const inheritedListenersEnv = "GOLAZE_LISTENERS"
+
+// handoffFile is a listener file handed to the restarted process
+type handoffFile struct {
+	key  string
+	file *os.File
+	// unixListener keeps its socket file once handed off
+	unixListener *net.UnixListener
+}
+
+// handoffComponent is implemented by components whose listeners are handed to
+// the restarted process
+type handoffComponent interface {
+	handoffFiles() ([]*handoffFile, error)
+}
+
+// handoffFiles collects the files of the started servers
+func handoffFiles(handoffs ...func() (*handoffFile, error)) ([]*handoffFile, error) {
+	var files []*handoffFile
+	for _, handoff := range handoffs {
+		f, err := handoff()
+		if err != nil {
+			return files, err
+		}
+		if f != nil {
+			files = append(files, f)
+		}
+	}
+
+	return files, nil
+}
+
+var (
+	inheritedOnce  sync.Once
+	inheritedLock  sync.Mutex
+	inheritedFiles map[string]*os.File
+)
+
+// inheritedFile returns the listener file handed by the previous process
+// under the key, once
+func inheritedFile(key string) *os.File {
+	inheritedOnce.Do(func() {
+		inheritedFiles = make(map[string]*os.File)
+
+		var keys []string
+		if err := json.Unmarshal([]byte(os.Getenv(inheritedListenersEnv)), &keys); err != nil {
+			return
+		}
+
+		// Extra files start at file descriptor 3
+		for i, key := range keys {
+			inheritedFiles[key] = os.NewFile(uintptr(3+i), key)
+		}
+	})
+
+	inheritedLock.Lock()
+	defer inheritedLock.Unlock()
+
+	f := inheritedFiles[key]
+	delete(inheritedFiles, key)
+
+	return f
+}
+
+func listenerKey(name, addr string) string {
+	return name + "@" + addr
+}
+
+// restart hands the listeners to a new process running the same executable
+// and waits for its readiness endpoint. The app is left running if the new
+// process doesn't become ready or the context ends first.
+func (app *App) restart(ctx context.Context, components []Component) (err error) {
+	// The readiness endpoint fails from now on, so it only succeeds once the
+	// new process answers on the shared socket and load balancers stop
+	// sending requests to this process
+	app.restarting.Store(true)
+	defer func() {
+		if err != nil {
+			app.restarting.Store(false)
+		}
+	}()
+
+	var handoffs []*handoffFile
+	defer func() {
+		for _, h := range handoffs {
+			h.file.Close()
+		}
+	}()
+
+	for _, component := range components {
+		c, ok := component.(handoffComponent)
+		if !ok {
+			continue
+		}
+
+		files, err := c.handoffFiles()
+		handoffs = append(handoffs, files...)
+		if err != nil {
+			return fmt.Errorf("%s listeners can't be handed off: %v", component.Name(), err)
+		}
+	}
+
+	keys := make([]string, 0, len(handoffs))
+	files := make([]*os.File, 0, len(handoffs))
+	for _, h := range handoffs {
+		keys = append(keys, h.key)
+		files = append(files, h.file)
+	}
+
+	env, err := json.Marshal(keys)
+	if err != nil {
+		return err
+	}
+
+	executable, err := os.Executable()
+	if err != nil {
+		return fmt.Errorf("failed to find executable: %v", err)
+	}
+
+	cmd := exec.Command(executable, os.Args[1:]...)
+	cmd.Stdin = os.Stdin
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	cmd.ExtraFiles = files
+	cmd.Env = append(environWithout(inheritedListenersEnv), inheritedListenersEnv+"="+string(env))
+
+	err = cmd.Start()
+
+	// The listeners of this process would otherwise block in accept, which
+	// Close can't interrupt
+	for _, h := range handoffs {
+		if err := setNonblock(h.file); err != nil {
+			log.Error().Err(err).Msgf("failed to restore non-blocking mode of %s", h.key)
+		}
+	}
+
+	if err != nil {
+		return fmt.Errorf("failed to start new process: %v", err)
+	}
+
+	// exited is closed once the new process exits, with its error in waitErr
+	exited := make(chan struct{})
+	var waitErr error
+	go func() {
+		waitErr = cmd.Wait()
+		close(exited)
+	}()
+
+	log.Info().Msgf("started new process %d, waiting for it to be ready", cmd.Process.Pid)
+
+	if err := app.waitReady(ctx, exited, &waitErr); err != nil {
+		cmd.Process.Kill()
+		<-exited
+
+		return err
+	}
+
+	log.Info().Msgf("new process %d is ready", cmd.Process.Pid)
+
+	// The socket files are used by the new process after this one exits
+	for _, h := range handoffs {
+		if h.unixListener != nil {
+			h.unixListener.SetUnlinkOnClose(false)
+		}
+	}
+
+	return nil
+}
+
+// waitReady polls the readiness endpoint of the health check address until
+// it succeeds, the new process exits, the context ends or RestartTimeout
+// elapses
+func (app *App) waitReady(ctx context.Context, exited <-chan struct{}, waitErr *error) error {
+	addr := app.HealthCheck.Addr()
+	if addr == nil {
+		return errors.New("health check server is not running")
+	}
+
+	client := &http.Client{
+		Timeout: time.Second,
+		Transport: &http.Transport{
+			DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+				var d net.Dialer
+				return d.DialContext(ctx, addr.Network(), addr.String())
+			},
+			DisableKeepAlives: true,
+		},
+	}
+
+	timeout := time.After(app.RestartTimeout)
+	ticker := time.NewTicker(100 * time.Millisecond)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-exited:
+			return fmt.Errorf("new process exited: %v", *waitErr)
+		case <-ctx.Done():
+			return fmt.Errorf("restart cancelled: %v", ctx.Err())
+		case <-timeout:
+			return fmt.Errorf("new process not ready after %s", app.RestartTimeout)
+		case <-ticker.C:
+			resp, err := client.Get("http://localhost/readiness")
+			if err != nil {
+				continue
+			}
+			resp.Body.Close()
+
+			if resp.StatusCode == http.StatusOK {
+				return nil
+			}
+		}
+	}
+}
+
+func environWithout(name string) []string {
+	env := make([]string, 0, len(os.Environ()))
+	for _, e := range os.Environ() {
+		if !strings.HasPrefix(e, name+"=") {
+			env = append(env, e)
+		}
+	}
+
+	return env
+}
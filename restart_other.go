@@ -0,0 +1,14 @@
+//go:build !unix
+
+package golaze
+
+import (
+	"os"
+)
+
+// restartSignals trigger a graceful restart, unsupported on this platform
+var restartSignals []os.Signal
+
+func setNonblock(f *os.File) error {
+	return nil
+}
@@ -0,0 +1,17 @@
+//go:build unix
+
+package golaze
+
+import (
+	"os"
+	"syscall"
+)
+
+// restartSignals trigger a graceful restart
+var restartSignals = []os.Signal{syscall.SIGUSR2}
+
+// setNonblock puts the handed off file back in non-blocking mode, shared with
+// the listener it was copied from, after exec made it blocking
+func setNonblock(f *os.File) error {
+	return syscall.SetNonblock(int(f.Fd()), true)
+}
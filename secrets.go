@@ -0,0 +1,198 @@
+package golaze
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+const redacted = "[REDACTED]"
+
+// Secret is a secret value that is redacted when printed, logged or encoded.
+// Use Value to get the actual secret.
+type Secret string
+
+// Value returns the secret value
+func (s Secret) Value() string {
+	return string(s)
+}
+
+func (s Secret) String() string {
+	return redacted
+}
+
+func (s Secret) GoString() string {
+	return redacted
+}
+
+func (s Secret) MarshalText() ([]byte, error) {
+	return []byte(redacted), nil
+}
+
+// SecretProvider loads secrets by name
+type SecretProvider interface {
+	GetSecret(ctx context.Context, name string) (Secret, error)
+}
+
+// SecretProviderFunc allows the use of ordinary functions as secret providers
+type SecretProviderFunc func(ctx context.Context, name string) (Secret, error)
+
+// GetSecret calls f(ctx, name)
+func (f SecretProviderFunc) GetSecret(ctx context.Context, name string) (Secret, error) {
+	return f(ctx, name)
+}
+
+// EnvSecretProvider reads secrets from environment variables. The name is
+// upper cased, with dashes and dots replaced by underscores, and prefixed.
+type EnvSecretProvider struct {
+	Prefix string
+}
+
+func (p *EnvSecretProvider) GetSecret(ctx context.Context, name string) (Secret, error) {
+	key := p.Prefix + strings.ToUpper(strings.NewReplacer("-", "_", ".", "_").Replace(name))
+
+	value, ok := os.LookupEnv(key)
+	if !ok {
+		return "", fmt.Errorf("secret %s not found in environment", name)
+	}
+
+	return Secret(value), nil
+}
+
+// FileSecretProvider reads secrets from files named after the secret in Dir,
+// such as Kubernetes mounted secrets. Files are read again when they change.
+type FileSecretProvider struct {
+	Dir string
+
+	lock  sync.Mutex
+	files map[string]*secretFile
+}
+
+type secretFile struct {
+	modTime time.Time
+	value   Secret
+}
+
+func (p *FileSecretProvider) GetSecret(ctx context.Context, name string) (Secret, error) {
+	if strings.Contains(name, "..") || strings.ContainsRune(name, filepath.Separator) {
+		return "", fmt.Errorf("invalid secret name %s", name)
+	}
+
+	path := filepath.Join(p.Dir, name)
+
+	// Stat follows the symlinks Kubernetes swaps when a secret is updated
+	info, err := os.Stat(path)
+	if err != nil {
+		return "", fmt.Errorf("secret %s not found: %v", name, err)
+	}
+
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	if f, ok := p.files[path]; ok && f.modTime.Equal(info.ModTime()) {
+		return f.value, nil
+	}
+
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return "", fmt.Errorf("failed to read secret %s: %v", name, err)
+	}
+
+	value := Secret(strings.TrimRight(string(b), "\r\n"))
+
+	if p.files == nil {
+		p.files = make(map[string]*secretFile)
+	}
+	p.files[path] = &secretFile{
+		modTime: info.ModTime(),
+		value:   value,
+	}
+
+	return value, nil
+}
+
+// CachedSecretProviderConfig configures a SecretProvider cache
+type CachedSecretProviderConfig struct {
+	Provider SecretProvider
+	// TTL is how long secrets are cached. Defaults to 5 minutes.
+	TTL time.Duration
+}
+
+// CachedSecretProvider caches the secrets of another provider
+type CachedSecretProvider struct {
+	*CachedSecretProviderConfig
+
+	lock    sync.Mutex
+	secrets map[string]*cachedSecret
+}
+
+type cachedSecret struct {
+	value     Secret
+	expiresAt time.Time
+}
+
+func NewCachedSecretProvider(config *CachedSecretProviderConfig) *CachedSecretProvider {
+	if config.TTL == 0 {
+		config.TTL = 5 * time.Minute
+	}
+
+	return &CachedSecretProvider{
+		CachedSecretProviderConfig: config,
+		secrets:                    make(map[string]*cachedSecret),
+	}
+}
+
+func (p *CachedSecretProvider) GetSecret(ctx context.Context, name string) (Secret, error) {
+	p.lock.Lock()
+	s, ok := p.secrets[name]
+	p.lock.Unlock()
+
+	if ok && time.Now().Before(s.expiresAt) {
+		return s.value, nil
+	}
+
+	value, err := p.Provider.GetSecret(ctx, name)
+	if err != nil {
+		return "", err
+	}
+
+	p.lock.Lock()
+	p.secrets[name] = &cachedSecret{
+		value:     value,
+		expiresAt: time.Now().Add(p.TTL),
+	}
+	p.lock.Unlock()
+
+	return value, nil
+}
+
+// Invalidate drops the cached secret so it is loaded again on next use
+func (p *CachedSecretProvider) Invalidate(name string) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	delete(p.secrets, name)
+}
+
+// SecretAuthTokens returns a token loader for NewAuthMiddleware reading
+// the named secrets from the provider
+func SecretAuthTokens(provider SecretProvider, names ...string) func() ([]*AuthToken, error) {
+	return func() ([]*AuthToken, error) {
+		tokens := make([]*AuthToken, 0, len(names))
+		for _, name := range names {
+			secret, err := provider.GetSecret(context.Background(), name)
+			if err != nil {
+				return nil, err
+			}
+			tokens = append(tokens, &AuthToken{
+				Token: secret.Value(),
+			})
+		}
+
+		return tokens, nil
+	}
+}
@@ -2,9 +2,11 @@ package golaze
 
 import (
 	"context"
+	"fmt"
 	"sync"
 	"time"
 
+	"github.com/rs/zerolog"
 	"github.com/rs/zerolog/log"
 )
 
@@ -17,6 +19,14 @@ type TaskConfig struct {
 	RepeatDelay   time.Duration
 	Timeout       time.Duration
 	RunHistory    []time.Time
+	// Queue is the name of the worker pool running the task. Empty means
+	// DefaultQueue.
+	Queue string
+
+	// CorrelationID and CausationID link the task to the request or event
+	// that created it.
+	CorrelationID string
+	CausationID   string
 
 	Cancel chan bool
 	Done   chan bool
@@ -60,52 +70,164 @@ func NewTask(config *TaskConfig) *Task {
 	}
 }
 
+// Context returns a copy of ctx carrying the task correlation and causation IDs
+func (t *Task) Context(ctx context.Context) context.Context {
+	if t.CorrelationID != "" {
+		ctx = ContextWithCorrelationID(ctx, t.CorrelationID)
+	}
+
+	if t.CausationID != "" {
+		ctx = ContextWithCausationID(ctx, t.CausationID)
+	}
+
+	return ctx
+}
+
+func (t *Task) logger() zerolog.Logger {
+	l := log.With()
+	if t.CorrelationID != "" {
+		l = l.Str("correlation_id", t.CorrelationID)
+	}
+
+	if t.CausationID != "" {
+		l = l.Str("causation_id", t.CausationID)
+	}
+
+	return l.Logger()
+}
+
 func (t *Task) Run(ctx context.Context, state *State) {
+	logger := t.logger()
 	taskError := make(chan error)
+	failed := false
 	go func() {
 		go func() {
 			t.lock.Lock()
 			t.RunHistory = append(t.RunHistory, time.Now())
 			t.lock.Unlock()
 
-			log.Info().Msgf("task %s started", t.Name)
+			logger.Info().Msgf("task %s started", t.Name)
 			err := t.Exec(state, t.Cancel)
 			taskError <- err
 		}()
 
 		select {
 		case <-ctx.Done():
-			log.Info().Msgf("task %s stopped", t.Name)
+			logger.Info().Msgf("task %s stopped", t.Name)
 		case <-t.Cancel:
-			log.Info().Msgf("task %s cancelled", t.Name)
+			logger.Info().Msgf("task %s cancelled", t.Name)
 		case err := <-taskError:
 			if err != nil {
-				log.Error().Err(err).Msgf("task %s failed", t.Name)
+				failed = true
+				logger.Error().Err(err).Msgf("task %s failed", t.Name)
 			} else {
-				log.Info().Msgf("task %s completed", t.Name)
+				logger.Info().Msgf("task %s completed", t.Name)
 			}
 		case <-time.After(t.Timeout):
-			log.Error().Msgf("task %s timed out", t.Name)
+			failed = true
+			logger.Error().Msgf("task %s timed out", t.Name)
 		}
 
 		t.Done <- true
 	}()
 
 	<-t.Done
-	if taskError != nil && t.MaxRetries > 0 {
+	// Only failed runs are retried
+	if failed && t.MaxRetries > 0 {
 		t.MaxRetries--
-		time.Sleep(t.RetryInterval)
+		if !wait(ctx, t.RetryInterval) {
+			return
+		}
 		t.Run(ctx, state)
 	}
 
 	if t.Repeat > 0 {
 		t.Repeat--
-		time.Sleep(t.RepeatDelay)
+		if !wait(ctx, t.RepeatDelay) {
+			return
+		}
 		t.Run(ctx, state)
 	}
 
 	if t.Repeat == -1 {
-		time.Sleep(t.RepeatDelay)
+		if !wait(ctx, t.RepeatDelay) {
+			return
+		}
 		t.Run(ctx, state)
 	}
 }
+
+type drainingKey struct{}
+
+// contextWithDraining returns a copy of ctx ending task repeats and retries
+// once draining is closed, letting the running attempt finish
+func contextWithDraining(ctx context.Context, draining <-chan bool) context.Context {
+	return context.WithValue(ctx, drainingKey{}, draining)
+}
+
+// wait sleeps for d and reports whether the task may run again. Tasks run
+// by a worker pool give up their slot while waiting.
+func wait(ctx context.Context, d time.Duration) bool {
+	draining, _ := ctx.Value(drainingKey{}).(<-chan bool)
+	slot, _ := ctx.Value(slotKey{}).(*taskSlot)
+	slot.release()
+
+	select {
+	case <-time.After(d):
+		return slot.acquire(ctx)
+	case <-ctx.Done():
+		return false
+	case <-draining:
+		return false
+	}
+}
+
+// Execute runs the task synchronously, retrying up to MaxRetries times with
+// RetryInterval between attempts, and returns the error of the last attempt.
+// Repeat settings are ignored.
+func (t *Task) Execute(ctx context.Context, state *State) error {
+	logger := t.logger()
+
+	var err error
+	for attempt := 0; attempt <= t.MaxRetries; attempt++ {
+		if attempt > 0 {
+			select {
+			case <-ctx.Done():
+				return ctx.Err()
+			case <-time.After(t.RetryInterval):
+			}
+		}
+
+		err = t.execute(ctx, state)
+		if err == nil {
+			logger.Info().Msgf("task %s completed", t.Name)
+			return nil
+		}
+
+		logger.Error().Err(err).Msgf("task %s failed (attempt %d of %d)", t.Name, attempt+1, t.MaxRetries+1)
+	}
+
+	return err
+}
+
+func (t *Task) execute(ctx context.Context, state *State) error {
+	t.lock.Lock()
+	t.RunHistory = append(t.RunHistory, time.Now())
+	t.lock.Unlock()
+
+	taskError := make(chan error, 1)
+	go func() {
+		taskError <- t.Exec(state, t.Cancel)
+	}()
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-t.Cancel:
+		return fmt.Errorf("task %s cancelled", t.Name)
+	case err := <-taskError:
+		return err
+	case <-time.After(t.Timeout):
+		return fmt.Errorf("task %s timed out", t.Name)
+	}
+}
@@ -0,0 +1,38 @@
+package golaze
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestTaskRunRetries(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		runs int
+	}{
+		{name: "successful runs are not retried", runs: 1},
+		{name: "failed runs are retried", err: fmt.Errorf("failed"), runs: 3},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			task := NewTask(&TaskConfig{
+				Name:          "retry",
+				MaxRetries:    2,
+				RetryInterval: time.Millisecond,
+				Exec: func(state *State, cancel chan bool) error {
+					return tt.err
+				},
+			})
+
+			task.Run(context.Background(), &State{Data: make(map[string]interface{})})
+
+			if len(task.RunHistory) != tt.runs {
+				t.Errorf("task ran %d times, expected %d", len(task.RunHistory), tt.runs)
+			}
+		})
+	}
+}
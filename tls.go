@@ -0,0 +1,257 @@
+package golaze
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog/log"
+)
+
+// TLSConfig enables HTTPS on the web app when CertFile and KeyFile are set.
+// Certificate files are loaded again when they change.
+type TLSConfig struct {
+	CertFile string `env:"TLS_CERT_FILE" flag:"tls-cert-file" usage:"TLS certificate file"`
+	KeyFile  string `env:"TLS_KEY_FILE" flag:"tls-key-file" usage:"TLS private key file"`
+	// MinVersion is 1.0, 1.1, 1.2 or 1.3. Defaults to 1.2.
+	MinVersion string `env:"TLS_MIN_VERSION" flag:"tls-min-version" usage:"minimum TLS version"`
+	// CipherSuites are cipher suite names, e.g.
+	// TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256. TLS 1.3 suites are not
+	// configurable.
+	CipherSuites []string `env:"TLS_CIPHER_SUITES" flag:"tls-cipher-suites" usage:"comma separated TLS cipher suites"`
+	// ClientCAFile enables mutual TLS, client certificates must be signed by
+	// one of its CAs
+	ClientCAFile string `env:"TLS_CLIENT_CA_FILE" flag:"tls-client-ca-file" usage:"CA file verifying client certificates"`
+	// OptionalClientCert accepts clients without a certificate, verifying
+	// the ones given
+	OptionalClientCert bool `env:"TLS_OPTIONAL_CLIENT_CERT" flag:"tls-optional-client-cert" usage:"accept clients without a certificate"`
+	// RedirectAddress serves plain HTTP redirecting to HTTPS, e.g. ":80"
+	RedirectAddress string `env:"TLS_REDIRECT_ADDRESS" flag:"tls-redirect-address" usage:"address redirecting HTTP to HTTPS"`
+}
+
+// Enabled reports whether TLS is configured
+func (c *TLSConfig) Enabled() bool {
+	return c.CertFile != "" || c.KeyFile != ""
+}
+
+var tlsVersions = map[string]uint16{
+	"1.0": tls.VersionTLS10,
+	"1.1": tls.VersionTLS11,
+	"1.2": tls.VersionTLS12,
+	"1.3": tls.VersionTLS13,
+}
+
+// newTLSConfig builds the server tls.Config, loading the certificate once to
+// report errors at startup
+func (c *TLSConfig) newTLSConfig() (*tls.Config, error) {
+	if c.CertFile == "" || c.KeyFile == "" {
+		return nil, fmt.Errorf("TLS requires both a certificate and a key file")
+	}
+
+	certificates := &certificateReloader{
+		certFile: c.CertFile,
+		keyFile:  c.KeyFile,
+	}
+	if err := certificates.reload(); err != nil {
+		return nil, err
+	}
+
+	config := &tls.Config{
+		GetCertificate: certificates.GetCertificate,
+		MinVersion:     tls.VersionTLS12,
+	}
+
+	if c.MinVersion != "" {
+		version, ok := tlsVersions[c.MinVersion]
+		if !ok {
+			return nil, fmt.Errorf("unsupported TLS version %s", c.MinVersion)
+		}
+		config.MinVersion = version
+	}
+
+	if len(c.CipherSuites) > 0 {
+		suites := make(map[string]uint16)
+		for _, suite := range tls.CipherSuites() {
+			suites[suite.Name] = suite.ID
+		}
+
+		for _, name := range c.CipherSuites {
+			id, ok := suites[name]
+			if !ok {
+				return nil, fmt.Errorf("unsupported cipher suite %s", name)
+			}
+			config.CipherSuites = append(config.CipherSuites, id)
+		}
+	}
+
+	if c.ClientCAFile != "" {
+		b, err := os.ReadFile(c.ClientCAFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read client CA file: %v", err)
+		}
+
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(b) {
+			return nil, fmt.Errorf("no certificates found in client CA file %s", c.ClientCAFile)
+		}
+
+		config.ClientCAs = pool
+		config.ClientAuth = tls.RequireAndVerifyClientCert
+		if c.OptionalClientCert {
+			config.ClientAuth = tls.VerifyClientCertIfGiven
+		}
+	}
+
+	return config, nil
+}
+
+// certificateCheckInterval is the minimum time between two checks of the
+// certificate files
+const certificateCheckInterval = time.Second
+
+// certificateReloader serves the certificate, loading it again when the
+// files change
+type certificateReloader struct {
+	certFile string
+	keyFile  string
+
+	lock        sync.Mutex
+	certificate *tls.Certificate
+	modTimes    [2]time.Time
+	checked     time.Time
+}
+
+func (r *certificateReloader) GetCertificate(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+	// The files are checked at most once per interval, not on every handshake
+	r.lock.Lock()
+	changed := false
+	if now := time.Now(); now.Sub(r.checked) >= certificateCheckInterval {
+		r.checked = now
+		changed = r.changed()
+	}
+	r.lock.Unlock()
+
+	if changed {
+		// The current certificate is kept until valid files replace it
+		if err := r.reload(); err != nil {
+			log.Error().Err(err).Msg("failed to reload TLS certificate")
+		} else {
+			log.Info().Msg("TLS certificate reloaded")
+		}
+	}
+
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	return r.certificate, nil
+}
+
+func (r *certificateReloader) changed() bool {
+	modTimes, err := r.stat()
+	return err == nil && modTimes != r.modTimes
+}
+
+func (r *certificateReloader) stat() ([2]time.Time, error) {
+	var modTimes [2]time.Time
+	for i, file := range []string{r.certFile, r.keyFile} {
+		info, err := os.Stat(file)
+		if err != nil {
+			return modTimes, err
+		}
+		modTimes[i] = info.ModTime()
+	}
+
+	return modTimes, nil
+}
+
+func (r *certificateReloader) reload() error {
+	modTimes, err := r.stat()
+	if err != nil {
+		return fmt.Errorf("failed to read TLS certificate: %v", err)
+	}
+
+	certificate, err := tls.LoadX509KeyPair(r.certFile, r.keyFile)
+	if err != nil {
+		return fmt.Errorf("failed to load TLS certificate: %v", err)
+	}
+
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	r.certificate = &certificate
+	r.modTimes = modTimes
+
+	return nil
+}
+
+// ClientIdentity is the verified client certificate of a mutual TLS request
+type ClientIdentity struct {
+	CommonName     string
+	DNSNames       []string
+	EmailAddresses []string
+	URIs           []*url.URL
+	Certificate    *x509.Certificate
+}
+
+type clientIdentityKey struct{}
+
+// ContextWithClientIdentity returns a copy of ctx carrying the client identity
+func ContextWithClientIdentity(ctx context.Context, identity *ClientIdentity) context.Context {
+	return context.WithValue(ctx, clientIdentityKey{}, identity)
+}
+
+// ClientIdentityFromContext returns the client identity carried by ctx, if any
+func ClientIdentityFromContext(ctx context.Context) *ClientIdentity {
+	identity, _ := ctx.Value(clientIdentityKey{}).(*ClientIdentity)
+	return identity
+}
+
+// clientIdentityHandler adds the verified client certificate identity to the
+// request context
+func clientIdentityHandler(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.TLS != nil && len(r.TLS.VerifiedChains) > 0 && len(r.TLS.VerifiedChains[0]) > 0 {
+			cert := r.TLS.VerifiedChains[0][0]
+			r = r.WithContext(ContextWithClientIdentity(r.Context(), &ClientIdentity{
+				CommonName:     cert.Subject.CommonName,
+				DNSNames:       cert.DNSNames,
+				EmailAddresses: cert.EmailAddresses,
+				URIs:           cert.URIs,
+				Certificate:    cert,
+			}))
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// redirectHandler redirects requests to the HTTPS address
+func redirectHandler(httpsAddr string) http.Handler {
+	_, port, _ := net.SplitHostPort(httpsAddr)
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		host := r.Host
+		if h, _, err := net.SplitHostPort(host); err == nil {
+			host = h
+		}
+		if port != "" && port != "443" {
+			host = net.JoinHostPort(host, port)
+		}
+
+		target := url.URL{
+			Scheme:   "https",
+			Host:     host,
+			Path:     r.URL.Path,
+			RawQuery: r.URL.RawQuery,
+		}
+
+		http.Redirect(w, r, target.String(), http.StatusPermanentRedirect)
+	})
+}
@@ -0,0 +1,393 @@
+package golaze
+
+import (
+	"bytes"
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io"
+	"math/big"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// testCertificate is a locally generated certificate and its PEM files
+type testCertificate struct {
+	cert     *x509.Certificate
+	key      *ecdsa.PrivateKey
+	tls      tls.Certificate
+	certFile string
+	keyFile  string
+}
+
+// newTestCertificate generates a certificate signed by the parent, or self
+// signed CA when parent is nil, and writes it to the directory
+func newTestCertificate(t *testing.T, dir, name string, parent *testCertificate, client bool) *testCertificate {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("failed to generate key: %v", err)
+	}
+
+	serial, _ := rand.Int(rand.Reader, big.NewInt(1<<62))
+	template := &x509.Certificate{
+		SerialNumber: serial,
+		Subject:      pkix.Name{CommonName: name},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+	}
+
+	signer, signerKey := template, key
+	switch {
+	case parent == nil:
+		template.IsCA = true
+		template.BasicConstraintsValid = true
+		template.KeyUsage |= x509.KeyUsageCertSign
+	case client:
+		template.ExtKeyUsage = []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth}
+		signer, signerKey = parent.cert, parent.key
+	default:
+		template.ExtKeyUsage = []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth}
+		template.DNSNames = []string{"localhost"}
+		template.IPAddresses = []net.IP{net.ParseIP("127.0.0.1")}
+		signer, signerKey = parent.cert, parent.key
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, signer, &key.PublicKey, signerKey)
+	if err != nil {
+		t.Fatalf("failed to create certificate: %v", err)
+	}
+
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatalf("failed to parse certificate: %v", err)
+	}
+
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatalf("failed to marshal key: %v", err)
+	}
+
+	certPEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})
+	keyPEM := pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER})
+
+	tlsCert, err := tls.X509KeyPair(certPEM, keyPEM)
+	if err != nil {
+		t.Fatalf("failed to load key pair: %v", err)
+	}
+
+	c := &testCertificate{
+		cert:     cert,
+		key:      key,
+		tls:      tlsCert,
+		certFile: filepath.Join(dir, name+".crt"),
+		keyFile:  filepath.Join(dir, name+".key"),
+	}
+
+	if err := os.WriteFile(c.certFile, certPEM, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(c.keyFile, keyPEM, 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	return c
+}
+
+func TestNewTLSConfig(t *testing.T) {
+	dir := t.TempDir()
+	ca := newTestCertificate(t, dir, "ca", nil, false)
+	server := newTestCertificate(t, dir, "server", ca, false)
+
+	invalidFile := filepath.Join(dir, "invalid.pem")
+	if err := os.WriteFile(invalidFile, []byte("invalid"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name           string
+		config         TLSConfig
+		wantErr        bool
+		wantMinVersion uint16
+		wantClientAuth tls.ClientAuthType
+	}{
+		{
+			name:           "defaults",
+			config:         TLSConfig{CertFile: server.certFile, KeyFile: server.keyFile},
+			wantMinVersion: tls.VersionTLS12,
+		},
+		{
+			name:           "min version",
+			config:         TLSConfig{CertFile: server.certFile, KeyFile: server.keyFile, MinVersion: "1.3"},
+			wantMinVersion: tls.VersionTLS13,
+		},
+		{
+			name:           "client CA",
+			config:         TLSConfig{CertFile: server.certFile, KeyFile: server.keyFile, ClientCAFile: ca.certFile},
+			wantMinVersion: tls.VersionTLS12,
+			wantClientAuth: tls.RequireAndVerifyClientCert,
+		},
+		{
+			name:           "optional client certificate",
+			config:         TLSConfig{CertFile: server.certFile, KeyFile: server.keyFile, ClientCAFile: ca.certFile, OptionalClientCert: true},
+			wantMinVersion: tls.VersionTLS12,
+			wantClientAuth: tls.VerifyClientCertIfGiven,
+		},
+		{
+			name:    "missing key file",
+			config:  TLSConfig{CertFile: server.certFile},
+			wantErr: true,
+		},
+		{
+			name:    "invalid certificate",
+			config:  TLSConfig{CertFile: invalidFile, KeyFile: server.keyFile},
+			wantErr: true,
+		},
+		{
+			name:    "unsupported version",
+			config:  TLSConfig{CertFile: server.certFile, KeyFile: server.keyFile, MinVersion: "2.0"},
+			wantErr: true,
+		},
+		{
+			name:    "unsupported cipher suite",
+			config:  TLSConfig{CertFile: server.certFile, KeyFile: server.keyFile, CipherSuites: []string{"TLS_NULL"}},
+			wantErr: true,
+		},
+		{
+			name:    "invalid client CA",
+			config:  TLSConfig{CertFile: server.certFile, KeyFile: server.keyFile, ClientCAFile: invalidFile},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config, err := tt.config.newTLSConfig()
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("error = %v, want error %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+
+			if config.MinVersion != tt.wantMinVersion {
+				t.Errorf("MinVersion = %x, want %x", config.MinVersion, tt.wantMinVersion)
+			}
+			if config.ClientAuth != tt.wantClientAuth {
+				t.Errorf("ClientAuth = %v, want %v", config.ClientAuth, tt.wantClientAuth)
+			}
+		})
+	}
+}
+
+func TestWebAppTLS(t *testing.T) {
+	dir := t.TempDir()
+	ca := newTestCertificate(t, dir, "ca", nil, false)
+	server := newTestCertificate(t, dir, "server", ca, false)
+	client := newTestCertificate(t, dir, "client", ca, true)
+
+	tests := []struct {
+		name         string
+		config       TLSConfig
+		clientCert   bool
+		wantErr      bool
+		wantIdentity string
+	}{
+		{
+			name:   "TLS",
+			config: TLSConfig{CertFile: server.certFile, KeyFile: server.keyFile},
+		},
+		{
+			name:         "mutual TLS",
+			config:       TLSConfig{CertFile: server.certFile, KeyFile: server.keyFile, ClientCAFile: ca.certFile},
+			clientCert:   true,
+			wantIdentity: "client",
+		},
+		{
+			name:    "mutual TLS without client certificate",
+			config:  TLSConfig{CertFile: server.certFile, KeyFile: server.keyFile, ClientCAFile: ca.certFile},
+			wantErr: true,
+		},
+		{
+			name:   "optional client certificate",
+			config: TLSConfig{CertFile: server.certFile, KeyFile: server.keyFile, ClientCAFile: ca.certFile, OptionalClientCert: true},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			router := NewRouter()
+			router.Get("/", func(w http.ResponseWriter, r *http.Request) {
+				if identity := ClientIdentityFromContext(r.Context()); identity != nil {
+					io.WriteString(w, identity.CommonName)
+				}
+			})
+
+			wa := NewWebApp(&WebAppConfig{
+				Address: "127.0.0.1:0",
+				Router:  router,
+				TLS:     tt.config,
+			})
+			if err := wa.Start(context.Background()); err != nil {
+				t.Fatalf("Start: %v", err)
+			}
+			defer wa.Stop(context.Background())
+
+			roots := x509.NewCertPool()
+			roots.AddCert(ca.cert)
+
+			clientConfig := &tls.Config{RootCAs: roots}
+			if tt.clientCert {
+				clientConfig.Certificates = []tls.Certificate{client.tls}
+			}
+
+			httpClient := &http.Client{
+				Timeout:   5 * time.Second,
+				Transport: &http.Transport{TLSClientConfig: clientConfig},
+			}
+
+			resp, err := httpClient.Get("https://" + wa.Addr().String() + "/")
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("request error = %v, want error %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			defer resp.Body.Close()
+
+			body, _ := io.ReadAll(resp.Body)
+			if resp.StatusCode != http.StatusOK {
+				t.Fatalf("status = %d, want 200", resp.StatusCode)
+			}
+			if string(body) != tt.wantIdentity {
+				t.Errorf("client identity = %q, want %q", body, tt.wantIdentity)
+			}
+		})
+	}
+}
+
+func TestWebAppRedirectAddressInUse(t *testing.T) {
+	dir := t.TempDir()
+	ca := newTestCertificate(t, dir, "ca", nil, false)
+	server := newTestCertificate(t, dir, "server", ca, false)
+
+	busy, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer busy.Close()
+
+	wa := NewWebApp(&WebAppConfig{
+		Address: "127.0.0.1:0",
+		TLS: TLSConfig{
+			CertFile:        server.certFile,
+			KeyFile:         server.keyFile,
+			RedirectAddress: busy.Addr().String(),
+		},
+	})
+	if err := wa.Start(context.Background()); err == nil {
+		wa.Stop(context.Background())
+		t.Fatal("Start succeeded with the redirect address in use")
+	}
+
+	// The main server is stopped when the redirect server fails to start
+	if conn, err := net.Dial("tcp", wa.Addr().String()); err == nil {
+		conn.Close()
+		t.Error("web app server still listening")
+	}
+
+	if err := wa.Stop(context.Background()); err != nil {
+		t.Errorf("Stop: %v", err)
+	}
+}
+
+func TestCertificateReload(t *testing.T) {
+	dir := t.TempDir()
+	ca := newTestCertificate(t, dir, "ca", nil, false)
+	first := newTestCertificate(t, dir, "server", ca, false)
+
+	reloader := &certificateReloader{certFile: first.certFile, keyFile: first.keyFile}
+	if err := reloader.reload(); err != nil {
+		t.Fatalf("reload: %v", err)
+	}
+
+	if _, err := reloader.GetCertificate(nil); err != nil {
+		t.Fatalf("GetCertificate: %v", err)
+	}
+
+	// The second certificate overwrites the files of the first one
+	second := newTestCertificate(t, dir, "server", ca, false)
+	later := time.Now().Add(time.Minute)
+	for _, file := range []string{second.certFile, second.keyFile} {
+		if err := os.Chtimes(file, later, later); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// The files aren't checked again within the interval
+	certificate, err := reloader.GetCertificate(nil)
+	if err != nil {
+		t.Fatalf("GetCertificate: %v", err)
+	}
+	if !bytes.Equal(certificate.Certificate[0], first.cert.Raw) {
+		t.Error("certificate reloaded within the check interval")
+	}
+
+	reloader.checked = time.Now().Add(-certificateCheckInterval)
+
+	certificate, err = reloader.GetCertificate(nil)
+	if err != nil {
+		t.Fatalf("GetCertificate: %v", err)
+	}
+	if !bytes.Equal(certificate.Certificate[0], second.cert.Raw) {
+		t.Error("certificate not reloaded")
+	}
+}
+
+func TestRedirectHandler(t *testing.T) {
+	tests := []struct {
+		name      string
+		httpsAddr string
+		target    string
+		want      string
+	}{
+		{
+			name:      "default port",
+			httpsAddr: ":443",
+			target:    "http://example.com/path?q=1",
+			want:      "https://example.com/path?q=1",
+		},
+		{
+			name:      "custom port",
+			httpsAddr: ":8443",
+			target:    "http://example.com:8080/path",
+			want:      "https://example.com:8443/path",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			redirectHandler(tt.httpsAddr).ServeHTTP(rec, httptest.NewRequest(http.MethodGet, tt.target, nil))
+
+			if rec.Code != http.StatusPermanentRedirect {
+				t.Errorf("status = %d, want %d", rec.Code, http.StatusPermanentRedirect)
+			}
+			if got := rec.Header().Get("Location"); got != tt.want {
+				t.Errorf("Location = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -1,18 +1,40 @@
 package golaze
 
 import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"net"
+	"net/http"
 	"os"
+	"strings"
 
 	"github.com/go-chi/chi/v5"
+	"github.com/rs/zerolog/log"
 )
 
 type WebAppConfig struct {
-	Port   string
-	Router *chi.Mux
+	Port string `env:"PORT" flag:"port" usage:"web app port"`
+	// Address is the host and port listened on, overriding Port
+	Address      string `env:"ADDRESS" flag:"address" usage:"web app listen address, overrides the port"`
+	Router       *chi.Mux
+	ServerConfig `yaml:",inline"`
+	TLS          TLSConfig
+	HTTP2        HTTP2Config
+	// HTTP3 serves HTTP/3 over QUIC on the UDP port of the address. It
+	// requires TLS. Shutdown waits for clients to close their connections
+	// after GOAWAY, up to the shutdown timeout.
+	HTTP3 bool `env:"HTTP3" flag:"http3" usage:"serve HTTP/3 over QUIC, requires TLS"`
+	// HTTP3Allow0RTT accepts 0-RTT data from resumed HTTP/3 connections.
+	// Early data can be replayed, only enable it for idempotent handlers.
+	HTTP3Allow0RTT bool `env:"HTTP3_ALLOW_0RTT" flag:"http3-allow-0rtt" usage:"accept replayable 0-RTT data over HTTP/3"`
 }
 
 type WebApp struct {
 	*WebAppConfig
+	server   httpServer
+	redirect httpServer
+	quic     http3Server
 }
 
 func NewWebApp(config *WebAppConfig) *WebApp {
@@ -30,6 +52,130 @@ func NewWebApp(config *WebAppConfig) *WebApp {
 	}
 
 	return &WebApp{
-		config,
+		WebAppConfig: config,
 	}
 }
+
+func (wa *WebApp) Name() string {
+	return "web-app"
+}
+
+// Start starts the web app server, with the HTTP/3 and HTTP to HTTPS redirect
+// servers when configured
+func (wa *WebApp) Start(ctx context.Context) error {
+	addr := wa.addr()
+
+	var tlsConfig *tls.Config
+	if wa.TLS.Enabled() {
+		var err error
+		if tlsConfig, err = wa.TLS.newTLSConfig(); err != nil {
+			return err
+		}
+	}
+
+	if wa.HTTP3 && tlsConfig == nil {
+		return errors.New("HTTP/3 requires TLS")
+	}
+
+	var handler http.Handler = wa.Router
+	if wa.TLS.ClientCAFile != "" {
+		handler = clientIdentityHandler(handler)
+	}
+
+	h3Handler := handler
+	if wa.HTTP3 {
+		handler = wa.quic.altSvc(handler)
+	}
+
+	server := wa.newServer(addr, handler)
+	if tlsConfig != nil {
+		server.TLSConfig = tlsConfig
+	}
+
+	if err := wa.HTTP2.configure(server); err != nil {
+		return err
+	}
+
+	if err := wa.server.start("main", server, &wa.ServerConfig); err != nil {
+		return err
+	}
+
+	log.Info().Msgf("web app server listening on %s%s", wa.Addr(), wa.protocols(server))
+
+	// HTTP/3 and redirects use the port picked when listening on port 0
+	if tcpAddr, ok := wa.Addr().(*net.TCPAddr); ok {
+		addr = tcpAddr.String()
+	} else if wa.HTTP3 {
+		wa.server.stop(ctx)
+		return errors.New("HTTP/3 requires a TCP address")
+	}
+
+	// Failures of any of the servers are reported together
+	wa.quic.failed = wa.server.failed
+	wa.redirect.failed = wa.server.failed
+
+	if wa.HTTP3 {
+		if err := wa.quic.start(addr, h3Handler, tlsConfig, wa.HTTP2.MaxConcurrentStreams, wa.HTTP3Allow0RTT); err != nil {
+			wa.server.stop(ctx)
+			return err
+		}
+	}
+
+	if wa.TLS.RedirectAddress != "" {
+		log.Info().Msgf("starting HTTPS redirect server on %s", wa.TLS.RedirectAddress)
+
+		redirect := (&ServerConfig{}).newServer(wa.TLS.RedirectAddress, redirectHandler(addr))
+		if err := wa.redirect.start("redirect", redirect, &ServerConfig{}); err != nil {
+			wa.Stop(ctx)
+			return err
+		}
+	}
+
+	return nil
+}
+
+// protocols describes the protocols served for the startup log
+func (wa *WebApp) protocols(server *http.Server) string {
+	var protocols []string
+	if server.TLSConfig != nil {
+		protocols = append(protocols, "TLS")
+	}
+	if wa.HTTP2.H2C {
+		protocols = append(protocols, "h2c")
+	}
+	if wa.HTTP3 {
+		protocols = append(protocols, "HTTP/3")
+	}
+
+	if len(protocols) == 0 {
+		return ""
+	}
+	return " with " + strings.Join(protocols, ", ")
+}
+
+func (wa *WebApp) addr() string {
+	if wa.Address != "" {
+		return wa.Address
+	}
+	return ":" + wa.Port
+}
+
+func (wa *WebApp) handoffFiles() ([]*handoffFile, error) {
+	return handoffFiles(wa.server.handoff, wa.redirect.handoff, wa.quic.handoff)
+}
+
+// Addr returns the address the web app server listens on, such as the port
+// picked when listening on port 0. It is nil until the server starts.
+func (wa *WebApp) Addr() net.Addr {
+	return wa.server.addr()
+}
+
+// Stop gracefully shuts down the web app server
+func (wa *WebApp) Stop(ctx context.Context) error {
+	return errors.Join(wa.redirect.stop(ctx), wa.quic.stop(ctx), wa.server.stop(ctx))
+}
+
+// Failed receives the error of the web app server failing while running
+func (wa *WebApp) Failed() <-chan error {
+	return wa.server.failed
+}
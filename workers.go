@@ -2,19 +2,42 @@ package golaze
 
 import (
 	"context"
+	"fmt"
 	"sync"
+	"time"
 
 	"github.com/rs/zerolog/log"
 )
 
+// DefaultQueue is the queue of workers and tasks that don't name one
+const DefaultQueue = "default"
+
+// TaskFactory builds a task from a payload, e.g. one received from a queue
+type TaskFactory func(payload []byte) (*Task, error)
+
 type WorkerConfig struct {
-	Tasks           []*Task
-	EventBus        *EventBus
+	// Queue names the worker pool, tasks are routed to the pool of their
+	// queue. Defaults to DefaultQueue.
+	Queue    string
+	Tasks    []*Task
+	EventBus *EventBus
+	// ConcurrentTasks limits the tasks running at once. Tasks waiting to
+	// repeat or retry give up their slot until they run again. Defaults to 2.
 	ConcurrentTasks int
+	TaskFactories   map[string]TaskFactory
+	// MaxRetries and RetryInterval apply to the tasks of the pool that don't
+	// set MaxRetries
+	MaxRetries    int
+	RetryInterval time.Duration
 }
 
 type Worker struct {
 	*WorkerConfig
+	lock sync.RWMutex
+
+	server *WorkerServer
+	cancel context.CancelFunc
+	router *workerRouter
 }
 
 type WorkerServer struct {
@@ -22,6 +45,58 @@ type WorkerServer struct {
 	state     *State
 	lock      sync.Mutex
 	shutdown  chan bool
+	stop      sync.Once
+
+	worker *Worker
+	slots  chan struct{}
+	// loops and running track the dispatch loops and the running tasks
+	// drained on shutdown
+	loops   sync.WaitGroup
+	running sync.WaitGroup
+
+	subscription *Subscription
+}
+
+// workerRouter finds the worker server of a queue
+type workerRouter struct {
+	lock    sync.RWMutex
+	servers map[string]*WorkerServer
+}
+
+func newWorkerRouter() *workerRouter {
+	return &workerRouter{
+		servers: make(map[string]*WorkerServer),
+	}
+}
+
+func (r *workerRouter) add(queue string, server *WorkerServer) error {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	if _, ok := r.servers[queue]; ok {
+		return fmt.Errorf("worker for queue %s already running", queue)
+	}
+
+	r.servers[queue] = server
+	return nil
+}
+
+func (r *workerRouter) remove(queue string) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	delete(r.servers, queue)
+}
+
+func (r *workerRouter) get(queue string) *WorkerServer {
+	if r == nil {
+		return nil
+	}
+
+	r.lock.RLock()
+	defer r.lock.RUnlock()
+
+	return r.servers[queue]
 }
 
 type TaskEventHandler struct {
@@ -29,9 +104,30 @@ type TaskEventHandler struct {
 }
 
 func (h *TaskEventHandler) Handle(event *Event) error {
-	log.Info().Msgf("event received: %v", event.Data)
-	task := event.Data.(*Task)
-	h.WorkerServer.AddTask(task)
+	task, ok := event.Data.(*Task)
+	if !ok {
+		log.Error().Str("event_id", event.ID).Msgf("task event carries %T, not a task", event.Data)
+		return fmt.Errorf("task event %s carries %T, not a task", event.ID, event.Data)
+	}
+
+	// Every worker pool on the bus receives the event, only the pool of the
+	// task queue handles it
+	if !h.WorkerServer.accepts(task) {
+		return nil
+	}
+
+	log.Info().Str("event_id", event.ID).Str("correlation_id", event.CorrelationID).Msgf("event received: %v", event.Data)
+	if task.CorrelationID == "" {
+		task.CorrelationID = event.CorrelationID
+	}
+	if task.CausationID == "" {
+		task.CausationID = event.ID
+	}
+
+	if err := h.WorkerServer.AddTask(task); err != nil {
+		log.Error().Err(err).Str("event_id", event.ID).Msgf("failed to add task %s", task.Name)
+		return err
+	}
 	return nil
 }
 
@@ -46,6 +142,14 @@ func NewWorker(config *WorkerConfig) *Worker {
 		config.ConcurrentTasks = 2
 	}
 
+	if config.TaskFactories == nil {
+		config.TaskFactories = make(map[string]TaskFactory)
+	}
+
+	if config.Queue == "" {
+		config.Queue = DefaultQueue
+	}
+
 	w := &Worker{
 		WorkerConfig: config,
 	}
@@ -53,6 +157,80 @@ func NewWorker(config *WorkerConfig) *Worker {
 	return w
 }
 
+// RegisterTask registers a task factory under the given name
+func (w *Worker) RegisterTask(name string, factory TaskFactory) {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+
+	w.TaskFactories[name] = factory
+}
+
+// NewTask builds the task registered under the given name
+func (w *Worker) NewTask(name string, payload []byte) (*Task, error) {
+	w.lock.RLock()
+	factory, ok := w.TaskFactories[name]
+	w.lock.RUnlock()
+
+	if !ok {
+		return nil, fmt.Errorf("task %s is not registered", name)
+	}
+
+	task, err := factory(payload)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create task %s: %v", name, err)
+	}
+
+	if task.Name == "" {
+		task.Name = name
+	}
+
+	return task, nil
+}
+
+func (w *Worker) Name() string {
+	if w.Queue == DefaultQueue {
+		return "worker"
+	}
+	return "worker-" + w.Queue
+}
+
+// Start runs the worker tasks on a new worker server
+func (w *Worker) Start(ctx context.Context) error {
+	server := NewWorkerServer()
+	server.configure(w)
+
+	if w.router != nil {
+		if err := w.router.add(w.Queue, server); err != nil {
+			return err
+		}
+	}
+
+	ctx, w.cancel = context.WithCancel(ctx)
+	w.server = server
+
+	// The worker is subscribed once Start returns, only dispatching runs in
+	// the background
+	log.Info().Msgf("starting worker server for queue %s", w.Queue)
+	w.server.run(ctx, w)
+
+	return nil
+}
+
+// Stop shuts down the worker server, waiting for the running tasks before
+// cancelling the ones left when the context ends
+func (w *Worker) Stop(ctx context.Context) error {
+	if w.server == nil {
+		return nil
+	}
+
+	if w.router != nil {
+		w.router.remove(w.Queue)
+	}
+
+	defer w.cancel()
+	return w.server.Shutdown(ctx)
+}
+
 // NewWorkerServer creates a new worker server
 func NewWorkerServer() *WorkerServer {
 	taskQueue := &TaskQueue{
@@ -72,45 +250,232 @@ func NewWorkerServer() *WorkerServer {
 	}
 }
 
-// AddTask adds a task to the worker
+// configure binds the server to the worker pool, once
+func (w *WorkerServer) configure(worker *Worker) {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+
+	if w.worker != nil {
+		return
+	}
+
+	w.worker = worker
+	w.slots = make(chan struct{}, worker.ConcurrentTasks)
+}
+
+// queue returns the queue served, or an empty string before the server starts
+func (w *WorkerServer) queue() string {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+
+	if w.worker == nil {
+		return ""
+	}
+	return w.worker.Queue
+}
+
+// accepts reports whether the server handles the task, either because it is
+// on its queue, or because no other pool does and the error belongs here
+func (w *WorkerServer) accepts(task *Task) bool {
+	queue := w.queue()
+	if queue == "" || taskQueue(task) == queue {
+		return true
+	}
+
+	if queue != DefaultQueue {
+		return false
+	}
+
+	return w.worker.router.get(taskQueue(task)) == nil
+}
+
+func taskQueue(task *Task) string {
+	if task.Queue == "" {
+		return DefaultQueue
+	}
+	return task.Queue
+}
+
+// AddTask adds a task to the worker. Tasks on another queue are routed to
+// the worker pool of their queue.
 func (w *WorkerServer) AddTask(task *Task) error {
-	w.taskQueue.enqueue <- *task
+	queue := w.queue()
+	if queue != "" && taskQueue(task) != queue {
+		target := w.worker.router.get(taskQueue(task))
+		if target == nil {
+			return fmt.Errorf("no worker for queue %s", taskQueue(task))
+		}
+		return target.AddTask(task)
+	}
+
+	w.applyDefaults(task)
+	select {
+	case w.taskQueue.enqueue <- *task:
+	case <-w.shutdown:
+		return fmt.Errorf("worker server is shut down")
+	}
 
 	return nil
 }
 
-// Start starts the worker
-func (w *WorkerServer) Start(ctx context.Context, worker *Worker) {
-	for _, task := range worker.Tasks {
-		w.AddTask(task)
+// applyDefaults sets the pool retry defaults on tasks without retries
+func (w *WorkerServer) applyDefaults(task *Task) {
+	if w.worker == nil || task.MaxRetries != 0 || w.worker.MaxRetries == 0 {
+		return
 	}
 
+	task.MaxRetries = w.worker.MaxRetries
+	if w.worker.RetryInterval != 0 {
+		task.RetryInterval = w.worker.RetryInterval
+	}
+}
+
+// RunTask runs the task synchronously with the server state, honoring its
+// timeout and retries, and returns the task error
+func (w *WorkerServer) RunTask(ctx context.Context, task *Task) error {
+	w.applyDefaults(task)
+	return task.Execute(task.Context(ctx), w.state)
+}
+
+// Start starts the worker, running at most ConcurrentTasks tasks at a time,
+// and blocks until the server shuts down
+func (w *WorkerServer) Start(ctx context.Context, worker *Worker) {
+	w.run(ctx, worker)
+	<-w.shutdown
+}
+
+// run subscribes to the event bus, starts the dispatch loops and queues the
+// worker tasks
+func (w *WorkerServer) run(ctx context.Context, worker *Worker) {
+	w.configure(worker)
+
 	if worker.EventBus != nil {
 		taskEventHandler := &TaskEventHandler{
 			WorkerServer: w,
 		}
-		worker.EventBus.Subscribe("task", taskEventHandler)
+		w.subscription = worker.EventBus.Subscribe("task", taskEventHandler)
 	}
 
+	// Repeats and retries end once the server shuts down
+	runCtx := contextWithDraining(ctx, w.shutdown)
+
+	w.loops.Add(2)
 	go func() {
+		defer w.loops.Done()
 		for {
 			select {
 			case task := <-w.taskQueue.enqueue:
-				w.taskQueue.dequeue <- task
-			case task := <-w.taskQueue.dequeue:
-				go task.Run(ctx, w.state)
+				select {
+				case w.taskQueue.dequeue <- task:
+				case <-w.shutdown:
+					return
+				}
 			case <-w.shutdown:
 				return
 			}
 		}
 	}()
 
-	<-w.shutdown
-	log.Info().Msg("worker server shutting down")
+	go func() {
+		defer w.loops.Done()
+		for {
+			var task Task
+			select {
+			case task = <-w.taskQueue.dequeue:
+			case <-w.shutdown:
+				return
+			}
+
+			// The task waits for a slot, shared with the tasks repeating
+			slot := &taskSlot{slots: w.slots}
+			if !slot.acquire(runCtx) {
+				return
+			}
+
+			w.running.Add(1)
+			go func() {
+				defer w.running.Done()
+				defer slot.release()
+				task.Run(contextWithSlot(runCtx, slot), w.state)
+			}()
+		}
+	}()
+
+	for _, task := range worker.Tasks {
+		// The worker tasks run on its own queue unless they name another
+		if task.Queue == "" {
+			task.Queue = worker.Queue
+		}
+		if err := w.AddTask(task); err != nil {
+			log.Error().Err(err).Msgf("failed to add task %s", task.Name)
+		}
+	}
+}
+
+type slotKey struct{}
+
+// taskSlot is the slot of a worker pool held by a running task
+type taskSlot struct {
+	slots chan struct{}
+	held  bool
+}
+
+func contextWithSlot(ctx context.Context, slot *taskSlot) context.Context {
+	return context.WithValue(ctx, slotKey{}, slot)
 }
 
-// Stop stops the worker
+// acquire waits for a free slot and reports whether it got one before the
+// context ended or the server started draining
+func (s *taskSlot) acquire(ctx context.Context) bool {
+	if s == nil || s.held {
+		return true
+	}
+
+	draining, _ := ctx.Value(drainingKey{}).(<-chan bool)
+
+	select {
+	case s.slots <- struct{}{}:
+		s.held = true
+		return true
+	case <-ctx.Done():
+		return false
+	case <-draining:
+		return false
+	}
+}
+
+// release frees the slot for other tasks
+func (s *taskSlot) release() {
+	if s == nil || !s.held {
+		return
+	}
+
+	<-s.slots
+	s.held = false
+}
+
+// Shutdown stops the worker server and waits for the dispatch loops and the
+// running tasks to finish until the context ends
 func (w *WorkerServer) Shutdown(ctx context.Context) error {
-	w.shutdown <- true
-	return nil
+	w.stop.Do(func() {
+		log.Info().Msg("worker server shutting down")
+		if w.subscription != nil {
+			w.subscription.Unsubscribe()
+		}
+		close(w.shutdown)
+	})
+
+	done := make(chan struct{})
+	go func() {
+		w.loops.Wait()
+		w.running.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return fmt.Errorf("worker server tasks still running: %w", ctx.Err())
+	}
 }
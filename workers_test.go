@@ -0,0 +1,49 @@
+package golaze
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestWorkerRepeatingTasksShareSlots(t *testing.T) {
+	runs := make([]atomic.Int32, 3)
+
+	tasks := make([]*Task, len(runs))
+	for i := range tasks {
+		i := i
+		tasks[i] = NewTask(&TaskConfig{
+			Name:        "repeat",
+			Repeat:      -1,
+			RepeatDelay: 5 * time.Millisecond,
+			Exec: func(state *State, cancel chan bool) error {
+				runs[i].Add(1)
+				time.Sleep(time.Millisecond)
+				return nil
+			},
+		})
+	}
+
+	worker := NewWorker(&WorkerConfig{
+		Tasks:           tasks,
+		ConcurrentTasks: 2,
+	})
+	if err := worker.Start(context.Background()); err != nil {
+		t.Fatalf("Start: %v", err)
+	}
+
+	time.Sleep(200 * time.Millisecond)
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	if err := worker.Stop(ctx); err != nil {
+		t.Fatalf("Stop: %v", err)
+	}
+
+	for i := range runs {
+		if runs[i].Load() == 0 {
+			t.Errorf("task %d never ran", i)
+		}
+	}
+}